@@ -0,0 +1,197 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// switches-gen generates fields and writes them as JSON, binary or text levels.
+//
+// Without -o, switches-gen writes one field to the standard output. With -o, switches-gen writes -n fields into the
+// directory. The i-th field is generated with the seed -seed + i.
+//
+// With -solve and -o, an unsolvable field is reported to the standard error, and switches-gen goes on with the other
+// fields and exits with a non-zero status at the end. A field too large to solve is reported as such but is not an
+// error.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+var (
+	flagWidth    = flag.Int("width", 4, "number of rooms in a row")
+	flagHeight   = flag.Int("height", 4, "number of rooms in a column")
+	flagDepth    = flag.Int("depth", 4, "number of floors")
+	flagSwitches = flag.Int("switches", 4, "number of switches")
+	flagSeed     = flag.Uint64("seed", 0, "seed of the first field (random if not specified)")
+	flagFormat   = flag.String("format", "text", "output format: json, binary or text")
+	flagSolve    = flag.Bool("solve", false, "solve the fields and print the optimal numbers of moves and the ratings")
+	flagN        = flag.Int("n", 1, "number of fields to generate with -o")
+	flagOut      = flag.String("o", "", "directory to write fields into")
+
+	flagOneWay   = flag.Float64("one-way", core.DefaultGeneratorConfig(0).OneWayRatio, "probability that a passage is one-way")
+	flagDeadEnds = flag.Float64("dead-ends", 0, "number of tries to add a dead-end room per room")
+	flagMinPath  = flag.Int("min-path", 0, "minimum number of moves between rooms in the generator's walk")
+
+	flagValidate    = flag.Bool("validate", false, "regenerate fields with problems such as unused switches or unreachable rooms")
+	flagMinSolution = flag.Int("min-solution", 0, "minimum number of moves of a solution with -validate")
+	flagMinRating   = flag.Float64("min-rating", 0, "minimum rating of the fields (0 for no bound)")
+	flagMaxRating   = flag.Float64("max-rating", 0, "maximum rating of the fields (0 for no bound)")
+)
+
+var extensions = map[string]string{
+	"json":   ".json",
+	"binary": ".bin",
+	"text":   ".txt",
+}
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ext, ok := extensions[*flagFormat]
+	if !ok {
+		return fmt.Errorf("switches-gen: invalid format: %s", *flagFormat)
+	}
+	seed := *flagSeed
+	seedSpecified := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSpecified = true
+		}
+	})
+	if !seedSpecified {
+		seed = rand.Uint64()
+		fmt.Fprintf(os.Stderr, "seed: %d\n", seed)
+	}
+
+	config := core.DefaultGeneratorConfig(*flagSwitches)
+	config.OneWayRatio = *flagOneWay
+	config.DeadEndDensity = *flagDeadEnds
+	config.MinPathLength = *flagMinPath
+	config.RejectInvalid = *flagValidate
+	config.MinSolutionLength = *flagMinSolution
+	config.MinRating = *flagMinRating
+	config.MaxRating = *flagMaxRating
+
+	if *flagOut == "" {
+		f, err := core.NewField(*flagWidth, *flagHeight, *flagDepth, *flagSwitches, seed, config)
+		if err != nil {
+			return err
+		}
+		if err := write(os.Stdout, f); err != nil {
+			return err
+		}
+		if *flagSolve {
+			result, err := solve(f)
+			if err != nil {
+				return err
+			}
+			// Print the result to the standard error not to mix it with the field.
+			fmt.Fprintln(os.Stderr, result)
+		}
+		return nil
+	}
+
+	if *flagN < 1 {
+		return fmt.Errorf("switches-gen: invalid number of fields: %d", *flagN)
+	}
+	if err := os.MkdirAll(*flagOut, 0755); err != nil {
+		return err
+	}
+	unsolvable := 0
+	for i := 0; i < *flagN; i++ {
+		s := seed + uint64(i)
+		f, err := core.NewField(*flagWidth, *flagHeight, *flagDepth, *flagSwitches, s, config)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*flagOut, fmt.Sprintf("%d%s", s, ext))
+		if err := writeFile(path, f); err != nil {
+			return err
+		}
+		if !*flagSolve {
+			continue
+		}
+		result, err := solve(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			unsolvable++
+			continue
+		}
+		fmt.Printf("%s: %s\n", path, result)
+	}
+	if 0 < unsolvable {
+		return fmt.Errorf("switches-gen: %d of %d fields are unsolvable", unsolvable, *flagN)
+	}
+	return nil
+}
+
+func writeFile(path string, f *core.Field) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file, f); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func write(w io.Writer, f *core.Field) error {
+	switch *flagFormat {
+	case "json":
+		b, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			return err
+		}
+		b = append(b, '\n')
+		_, err = w.Write(b)
+		return err
+	case "binary":
+		b, err := f.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "text":
+		return f.WriteLevel(w)
+	}
+	panic("not reach")
+}
+
+// solve returns the description of the optimal solution and the rating of the field.
+// A field too large to solve is not an error, as it is still a valid field.
+func solve(f *core.Field) (string, error) {
+	r, err := core.RateField(f)
+	if err == core.ErrTooLarge {
+		return "too large to solve", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d moves, %d flips, rating %.1f", r.Solution.Len(), r.Solution.Flips, r.Score), nil
+}
@@ -0,0 +1,375 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// switches-tui is a frontend of Switches for terminals.
+//
+// Use the arrow keys (or h, j, k and l) to move, z to undo, y to redo and q to quit.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+var (
+	flagLevel = flag.String("level", "", "path to a text level to play")
+	flagSeed  = flag.Uint64("seed", 0, "seed of the field (random if not specified)")
+)
+
+type key int
+
+const (
+	keyNone key = iota
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyUndo
+	keyRedo
+	keyQuit
+)
+
+var errQuit = errors.New("switches-tui: quit")
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var level *core.Field
+	if *flagLevel != "" {
+		f, err := os.Open(*flagLevel)
+		if err != nil {
+			return err
+		}
+		level, err = core.ParseLevel(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("switches-tui: the standard input is not a terminal")
+	}
+	old, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, old)
+	defer fmt.Print("\x1b[0m\x1b[?25h\r\n")
+	fmt.Print("\x1b[?25l")
+
+	t := &tui{
+		in:  bufio.NewReader(os.Stdin),
+		out: bufio.NewWriter(os.Stdout),
+	}
+	if level != nil {
+		if err := t.play(level); err != nil && err != errQuit {
+			return err
+		}
+		return nil
+	}
+	seed := *flagSeed
+	seedSpecified := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSpecified = true
+		}
+	})
+	for {
+		d, err := t.selectDifficulty()
+		if err == errQuit {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !seedSpecified {
+			seed = rand.Uint64()
+		}
+		t.clear()
+		t.printf("NOW LOADING...")
+		if err := t.out.Flush(); err != nil {
+			return err
+		}
+		f, err := d.NewField(seed)
+		if err != nil {
+			return err
+		}
+		if err := t.play(f); err == errQuit {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+type tui struct {
+	in  *bufio.Reader
+	out *bufio.Writer
+}
+
+func (t *tui) clear() {
+	t.out.WriteString("\x1b[H\x1b[2J")
+}
+
+// printf prints a line. printf is needed as the terminal is in the raw mode and "\n" doesn't return the carriage.
+func (t *tui) printf(format string, args ...interface{}) {
+	fmt.Fprintf(t.out, format, args...)
+	t.out.WriteString("\x1b[0m\x1b[K\r\n")
+}
+
+func (t *tui) readKey() (key, error) {
+	b, err := t.in.ReadByte()
+	if err != nil {
+		return keyNone, err
+	}
+	switch b {
+	case 0x1b:
+		// Arrow keys are "ESC [ A" and so on.
+		if t.in.Buffered() < 2 {
+			return keyNone, nil
+		}
+		b1, _ := t.in.ReadByte()
+		b2, _ := t.in.ReadByte()
+		if b1 != '[' && b1 != 'O' {
+			return keyNone, nil
+		}
+		switch b2 {
+		case 'A':
+			return keyUp, nil
+		case 'B':
+			return keyDown, nil
+		case 'C':
+			return keyRight, nil
+		case 'D':
+			return keyLeft, nil
+		}
+	case 'h':
+		return keyLeft, nil
+	case 'j':
+		return keyDown, nil
+	case 'k':
+		return keyUp, nil
+	case 'l':
+		return keyRight, nil
+	case 'z':
+		return keyUndo, nil
+	case 'y':
+		return keyRedo, nil
+	case 'q', 0x03:
+		return keyQuit, nil
+	}
+	return keyNone, nil
+}
+
+func (t *tui) selectDifficulty() (*core.Difficulty, error) {
+	selected := 0
+	for {
+		t.clear()
+		t.printf("SWITCHES")
+		t.printf("")
+		for i, d := range core.Difficulties {
+			if i == selected {
+				t.printf("\x1b[1;33m> %s", d.Name)
+			} else {
+				t.printf("  %s", d.Name)
+			}
+		}
+		t.printf("")
+		t.printf("up/down: select  right: start  q: quit")
+		if err := t.out.Flush(); err != nil {
+			return nil, err
+		}
+		k, err := t.readKey()
+		if err != nil {
+			return nil, err
+		}
+		switch k {
+		case keyUp:
+			selected = (selected + len(core.Difficulties) - 1) % len(core.Difficulties)
+		case keyDown:
+			selected = (selected + 1) % len(core.Difficulties)
+		case keyRight:
+			return core.Difficulties[selected], nil
+		case keyQuit:
+			return nil, errQuit
+		}
+	}
+}
+
+func (t *tui) play(f *core.Field) error {
+	state := core.NewState(f)
+	// Like the game, a field too large to solve or unsolvable is played without the par.
+	var par *core.Solution
+	if sol, err := core.Solve(f, state); err == nil {
+		par = sol
+	} else if err != core.ErrTooLarge && err != core.ErrUnsolvable {
+		return err
+	}
+	var history core.History
+	undos := 0
+	for !state.IsGoal(f) {
+		t.draw(f, state)
+		if err := t.out.Flush(); err != nil {
+			return err
+		}
+		k, err := t.readKey()
+		if err != nil {
+			return err
+		}
+		var d core.Dir
+		switch k {
+		case keyLeft:
+			d = core.DirLeft
+		case keyRight:
+			d = core.DirRight
+		case keyUp:
+			d = core.DirUp
+		case keyDown:
+			d = core.DirDown
+		case keyUndo:
+			if s, ok := history.Undo(state); ok {
+				state = s
+				undos++
+			}
+			continue
+		case keyRedo:
+			if s, ok := history.Redo(state); ok {
+				state = s
+			}
+			continue
+		case keyQuit:
+			return errQuit
+		default:
+			continue
+		}
+		prev := state.Clone()
+		if state.Step(f, d) {
+			history.Push(prev)
+		}
+	}
+
+	t.draw(f, state)
+	t.printf("\x1b[1mGOAL!")
+	t.printf("MOVES %d  PAR %s  FLIPS %d  UNDOS %d", state.Steps, parString(par), state.Flips, undos)
+	t.printf("Press any key.")
+	if err := t.out.Flush(); err != nil {
+		return err
+	}
+	_, err := t.readKey()
+	return err
+}
+
+func parString(par *core.Solution) string {
+	if par == nil {
+		return "---"
+	}
+	return fmt.Sprint(par.Len())
+}
+
+func (t *tui) draw(f *core.Field, state *core.State) {
+	cols, rows, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || cols <= 0 || rows <= 0 {
+		cols, rows = 80, 24
+	}
+	// Each tile takes two columns. Leave some rows for the status.
+	vw := cols / 2
+	vh := rows - 5
+	x0 := state.X - vw/2
+	y0 := state.Y - vh/2
+
+	t.clear()
+	floor := "GROUND"
+	if state.Z != 0 {
+		floor = fmt.Sprintf("B%dF", state.Z)
+	}
+	t.printf("%s  MOVES %d  FLIPS %d", floor, state.Steps, state.Flips)
+	w, h, _ := f.TileSize()
+	for y := y0; y < y0+vh; y++ {
+		var line strings.Builder
+		for x := x0; x < x0+vw; x++ {
+			if x == state.X && y == state.Y {
+				line.WriteString("\x1b[1;46;97m@@\x1b[0m")
+				continue
+			}
+			if x < 0 || w <= x || y < 0 || h <= y {
+				line.WriteString("  ")
+				continue
+			}
+			tile, sw := f.Tile(x, y, state.Z, state.SwitchStates)
+			line.WriteString(tileString(tile, sw, state.SwitchStates))
+		}
+		t.printf("%s", line.String())
+	}
+	t.printf("arrows: move  z: undo  y: redo  q: quit")
+}
+
+func tileString(t core.Tile, sw int, switchStates []bool) string {
+	letter := string(rune('A' + sw))
+	switch t {
+	case core.TileNone:
+		return "  "
+	case core.TileRegular:
+		return "\x1b[47m  \x1b[0m"
+	case core.TileUpstairs:
+		return "\x1b[44;97mUP\x1b[0m"
+	case core.TileDownstairs:
+		return "\x1b[44;97mDN\x1b[0m"
+	case core.TileOneWayLeft:
+		return "\x1b[45;97m<<\x1b[0m"
+	case core.TileOneWayRight:
+		return "\x1b[45;97m>>\x1b[0m"
+	case core.TileOneWayUp:
+		return "\x1b[45;97m^^\x1b[0m"
+	case core.TileOneWayDown:
+		return "\x1b[45;97mvv\x1b[0m"
+	case core.TileOneWayUpstairs:
+		return "\x1b[45;97mU!\x1b[0m"
+	case core.TileOneWayDownstairs:
+		return "\x1b[45;97mD!\x1b[0m"
+	case core.TileSwitch0:
+		return "\x1b[43;90m*" + letter + "\x1b[0m"
+	case core.TileSwitch1:
+		return "\x1b[103;30m*" + letter + "\x1b[0m"
+	case core.TileSwitchedTileValid, core.TileSwitchedTileInvalid:
+		// Show whether the tile needs the switch on (+) or off (-).
+		need := "+"
+		if switchStates[sw] != (t == core.TileSwitchedTileValid) {
+			need = "-"
+		}
+		if t == core.TileSwitchedTileValid {
+			return "\x1b[42;30m" + letter + need + "\x1b[0m"
+		}
+		return "\x1b[41;97m" + letter + need + "\x1b[0m"
+	case core.TileGoal:
+		return "\x1b[102;30mGL\x1b[0m"
+	}
+	panic("not reach")
+}
@@ -2,7 +2,10 @@ module github.com/hajimehoshi/switches
 
 go 1.19
 
-require github.com/hajimehoshi/ebiten/v2 v2.7.5
+require (
+	github.com/hajimehoshi/ebiten/v2 v2.7.5
+	golang.org/x/term v0.19.0
+)
 
 require (
 	github.com/ebitengine/gomobile v0.0.0-20240518074828-e86332849895 // indirect
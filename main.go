@@ -15,14 +15,37 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"github.com/hajimehoshi/switches/switches"
 )
 
+var (
+	flagLevel  = flag.String("level", "", "path to a text level to play")
+	flagAssets = flag.String("assets", "", "directory with image files to use instead of the embedded ones")
+)
+
 func main() {
+	flag.Parse()
+	if *flagAssets != "" {
+		switches.SetAssetDir(*flagAssets)
+	}
 	g, err := switches.NewGame()
 	if err != nil {
 		panic(err)
 	}
+	if *flagLevel != "" {
+		f, err := os.Open(*flagLevel)
+		if err != nil {
+			panic(err)
+		}
+		err = g.LoadLevel(f)
+		f.Close()
+		if err != nil {
+			panic(err)
+		}
+	}
 	if err := g.Run(); err != nil {
 		panic(err)
 	}
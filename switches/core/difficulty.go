@@ -0,0 +1,47 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+)
+
+// Difficulty is a preset of the parameters of field generation.
+type Difficulty struct {
+	Name     string
+	Width    int
+	Height   int
+	Depth    int
+	Switches int
+
+	// Config is the generator config. If Config is nil, DefaultGeneratorConfig is used.
+	Config *GeneratorConfig
+}
+
+// Difficulties are the difficulties the game offers.
+var Difficulties = []*Difficulty{
+	{Name: "EASY", Width: 2, Height: 2, Depth: 2, Switches: 2},
+	{Name: "NORMAL", Width: 4, Height: 4, Depth: 4, Switches: 4},
+	{Name: "HARD", Width: 6, Height: 6, Depth: 6, Switches: 6},
+	{Name: "EXTREME", Width: 8, Height: 8, Depth: 8, Switches: 8},
+}
+
+func (d *Difficulty) NewField(seed uint64) (*Field, error) {
+	return NewField(d.Width, d.Height, d.Depth, d.Switches, seed, d.Config)
+}
+
+func (d *Difficulty) NewFieldContext(ctx context.Context, seed uint64, progress chan<- GeneratorProgress) (*Field, error) {
+	return NewFieldContext(ctx, d.Width, d.Height, d.Depth, d.Switches, seed, d.Config, progress)
+}
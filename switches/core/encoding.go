@@ -0,0 +1,570 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A field is encoded either in JSON or in a compact binary form. Both forms have the same information:
+// the dimensions, the number of switches and the rooms, or the text level if the field is loaded from a text level.
+// The seed is not encoded.
+//
+// Each room has the switches placed in it, whether it is the goal, and the passages to the right, down and downstairs.
+// The passages to the left, up and upstairs are the passages of the neighbor rooms in the opposite directions.
+// A passage has a requirement for each switch: don't care, need false (off) or need true (on).
+//
+// The JSON form looks like this:
+//
+//	{
+//	  "version": 3,
+//	  "width": 2,
+//	  "height": 2,
+//	  "depth": 2,
+//	  "switches": 2,
+//	  "rooms": [
+//	    {"x": 0, "y": 0, "z": 0, "switches": "A", "passages": {"right": "1-", "downstairs": "0-"}, "oneWay": {"right": "left"}},
+//	    {"x": 1, "y": 2, "z": 1, "goal": true}
+//	  ]
+//	}
+//
+// "switches" of a room lists the letters of the switches in the room. Each character of a passage is the requirement
+// for the switch: '-' (don't care), '0' (need false) or '1' (need true). "oneWay" maps a one-way passage to the only
+// direction the passage can be passed in.
+//
+// A field loaded from a text level is encoded as {"version": 3, "level": "..."} with the text of the level.
+//
+// The binary form consists of:
+//
+//	magic "SWFD"
+//	version: byte
+//	kind: byte (0: rooms, 1: text level)
+//
+// A text level is followed by its length (uvarint) and its text. Rooms are followed by:
+//
+//	width, height, depth, switches: uvarint
+//	the number of rooms: uvarint
+//	for each room:
+//	  x, y, z: uvarint
+//	  flags: byte (bit 0: goal, bit 1: right, bit 2: down, bit 3: downstairs)
+//	  switches: uvarint (bit i is set when switch i is in the room)
+//	  one-way: byte (bits 0-2: one-way right, down and downstairs, bits 3-5: passable only in the opposite direction)
+//	  for each passage in the order of right, down and downstairs:
+//	    requirements: 2 bits per switch (0: don't care, 1: need false, 2: need true), packed little-endian into bytes
+//
+// Version 1 had neither text levels nor the kind byte. Version 2 had no one-way passages. Both can still be decoded.
+
+// FieldFormatVersion is the version of the field encoding.
+const FieldFormatVersion = 3
+
+const (
+	binaryFieldKindRooms = 0
+	binaryFieldKindLevel = 1
+)
+
+const binaryFieldMagic = "SWFD"
+
+// encodedDirs are the directions of the passages encoded in a room.
+var encodedDirs = []Dir{DirRight, DirDown, DirDownstairs}
+
+var dirNames = map[Dir]string{
+	DirLeft:       "left",
+	DirRight:      "right",
+	DirUp:         "up",
+	DirDown:       "down",
+	DirUpstairs:   "upstairs",
+	DirDownstairs: "downstairs",
+}
+
+type jsonField struct {
+	Version  int         `json:"version"`
+	Width    int         `json:"width,omitempty"`
+	Height   int         `json:"height,omitempty"`
+	Depth    int         `json:"depth,omitempty"`
+	Switches int         `json:"switches,omitempty"`
+	Rooms    []*jsonRoom `json:"rooms,omitempty"`
+	Level    string      `json:"level,omitempty"`
+}
+
+type jsonRoom struct {
+	X        int               `json:"x"`
+	Y        int               `json:"y"`
+	Z        int               `json:"z"`
+	Switches string            `json:"switches,omitempty"`
+	Goal     bool              `json:"goal,omitempty"`
+	Passages map[string]string `json:"passages,omitempty"`
+	OneWay   map[string]string `json:"oneWay,omitempty"`
+}
+
+func (f *Field) MarshalJSON() ([]byte, error) {
+	if f.grid != nil {
+		var level strings.Builder
+		if err := f.WriteLevel(&level); err != nil {
+			return nil, err
+		}
+		return json.Marshal(&jsonField{
+			Version: FieldFormatVersion,
+			Level:   level.String(),
+		})
+	}
+	j := &jsonField{
+		Version:  FieldFormatVersion,
+		Width:    f.width,
+		Height:   f.height,
+		Depth:    f.depth,
+		Switches: f.switches,
+		Rooms:    []*jsonRoom{},
+	}
+	for _, r := range f.rooms {
+		if r == nil {
+			continue
+		}
+		jr := &jsonRoom{
+			X:    r.x,
+			Y:    r.y,
+			Z:    r.z,
+			Goal: r.goal,
+		}
+		for i, b := range r.switches {
+			if b {
+				jr.Switches += string(rune('A' + i))
+			}
+		}
+		for _, d := range encodedDirs {
+			p := r.dirs[d]
+			if p == nil {
+				continue
+			}
+			if jr.Passages == nil {
+				jr.Passages = map[string]string{}
+			}
+			var str strings.Builder
+			for _, t := range p.switches {
+				str.WriteByte("-01"[t])
+			}
+			jr.Passages[dirNames[d]] = str.String()
+			if p.oneWay {
+				if jr.OneWay == nil {
+					jr.OneWay = map[string]string{}
+				}
+				jr.OneWay[dirNames[d]] = dirNames[p.oneWayDir]
+			}
+		}
+		j.Rooms = append(j.Rooms, jr)
+	}
+	return json.Marshal(j)
+}
+
+func (f *Field) UnmarshalJSON(data []byte) error {
+	var j jsonField
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Version < 1 || FieldFormatVersion < j.Version {
+		return fmt.Errorf("core: unsupported field format version: %d", j.Version)
+	}
+	if j.Level != "" {
+		f2, err := ParseLevel(strings.NewReader(j.Level))
+		if err != nil {
+			return err
+		}
+		*f = *f2
+		return nil
+	}
+	d, err := newFieldDecoder(j.Width, j.Height, j.Depth, j.Switches)
+	if err != nil {
+		return err
+	}
+	for _, jr := range j.Rooms {
+		r, err := d.addRoom(jr.X, jr.Y, jr.Z)
+		if err != nil {
+			return err
+		}
+		r.goal = jr.Goal
+		for _, c := range jr.Switches {
+			i := int(c - 'A')
+			if i < 0 || j.Switches <= i {
+				return fmt.Errorf("core: invalid switch %q in room (%d, %d, %d)", c, r.x, r.y, r.z)
+			}
+			r.switches[i] = true
+		}
+		for name, str := range jr.Passages {
+			dir, ok := encodedDirByName(name)
+			if !ok {
+				return fmt.Errorf("core: invalid passage direction %q in room (%d, %d, %d)", name, r.x, r.y, r.z)
+			}
+			if len(str) != j.Switches {
+				return fmt.Errorf("core: passage %q in room (%d, %d, %d) must have %d requirements", name, r.x, r.y, r.z, j.Switches)
+			}
+			p := newPassage(j.Switches)
+			for i := 0; i < len(str); i++ {
+				t := strings.IndexByte("-01", str[i])
+				if t == -1 {
+					return fmt.Errorf("core: invalid requirement %q in room (%d, %d, %d)", str[i], r.x, r.y, r.z)
+				}
+				p.switches[i] = passageSwitchType(t)
+			}
+			r.dirs[dir] = p
+		}
+		for name, str := range jr.OneWay {
+			dir, ok := encodedDirByName(name)
+			if !ok || r.dirs[dir] == nil {
+				return fmt.Errorf("core: invalid one-way passage %q in room (%d, %d, %d)", name, r.x, r.y, r.z)
+			}
+			oneWayDir, ok := dirByName(str)
+			if !ok || (oneWayDir != dir && oneWayDir != dir.opposite()) {
+				return fmt.Errorf("core: invalid one-way direction %q in room (%d, %d, %d)", str, r.x, r.y, r.z)
+			}
+			r.dirs[dir].oneWay = true
+			r.dirs[dir].oneWayDir = oneWayDir
+		}
+	}
+	f2, err := d.field()
+	if err != nil {
+		return err
+	}
+	*f = *f2
+	return nil
+}
+
+func dirByName(name string) (Dir, bool) {
+	for d, n := range dirNames {
+		if n == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func encodedDirByName(name string) (Dir, bool) {
+	d, ok := dirByName(name)
+	if !ok {
+		return 0, false
+	}
+	for _, d2 := range encodedDirs {
+		if d == d2 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func (f *Field) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(binaryFieldMagic)
+	buf.WriteByte(FieldFormatVersion)
+	if f.grid != nil {
+		var level bytes.Buffer
+		if err := f.WriteLevel(&level); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(binaryFieldKindLevel)
+		writeUvarint(&buf, uint64(level.Len()))
+		buf.Write(level.Bytes())
+		return buf.Bytes(), nil
+	}
+	buf.WriteByte(binaryFieldKindRooms)
+	var rooms []*room
+	for _, r := range f.rooms {
+		if r != nil {
+			rooms = append(rooms, r)
+		}
+	}
+	for _, v := range []int{f.width, f.height, f.depth, f.switches, len(rooms)} {
+		writeUvarint(&buf, uint64(v))
+	}
+	for _, r := range rooms {
+		for _, v := range []int{r.x, r.y, r.z} {
+			writeUvarint(&buf, uint64(v))
+		}
+		var flags byte
+		if r.goal {
+			flags |= 1
+		}
+		for i, d := range encodedDirs {
+			if r.dirs[d] != nil {
+				flags |= 1 << uint(i+1)
+			}
+		}
+		buf.WriteByte(flags)
+		var switches uint64
+		for i, b := range r.switches {
+			if b {
+				switches |= 1 << uint(i)
+			}
+		}
+		writeUvarint(&buf, switches)
+		var oneWay byte
+		for i, d := range encodedDirs {
+			p := r.dirs[d]
+			if p == nil || !p.oneWay {
+				continue
+			}
+			oneWay |= 1 << uint(i)
+			if p.oneWayDir != d {
+				oneWay |= 1 << uint(i+3)
+			}
+		}
+		buf.WriteByte(oneWay)
+		for _, d := range encodedDirs {
+			p := r.dirs[d]
+			if p == nil {
+				continue
+			}
+			bs := make([]byte, (2*f.switches+7)/8)
+			for i, t := range p.switches {
+				bs[i/4] |= byte(t) << uint(2*(i%4))
+			}
+			buf.Write(bs)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (f *Field) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	magic := make([]byte, len(binaryFieldMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != binaryFieldMagic {
+		return errors.New("core: not a binary field")
+	}
+	version, err := r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if version < 1 || FieldFormatVersion < version {
+		return fmt.Errorf("core: unsupported field format version: %d", version)
+	}
+	kind := byte(binaryFieldKindRooms)
+	if 2 <= version {
+		k, err := r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		kind = k
+	}
+	switch kind {
+	case binaryFieldKindRooms:
+	case binaryFieldKindLevel:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if uint64(r.Len()) != n {
+			return errors.New("core: invalid length of the text level")
+		}
+		f2, err := ParseLevel(r)
+		if err != nil {
+			return err
+		}
+		*f = *f2
+		return nil
+	default:
+		return fmt.Errorf("core: invalid field kind: %d", kind)
+	}
+	var header [5]int
+	for i := range header {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if MaxFieldSize*MaxFieldSize*MaxFieldSize < v {
+			return fmt.Errorf("core: too large value in the header: %d", v)
+		}
+		header[i] = int(v)
+	}
+	d, err := newFieldDecoder(header[0], header[1], header[2], header[3])
+	if err != nil {
+		return err
+	}
+	switchNum := header[3]
+	for i := 0; i < header[4]; i++ {
+		var pos [3]int
+		for j := range pos {
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if MaxFieldSize < v {
+				return fmt.Errorf("core: invalid room position: %d", v)
+			}
+			pos[j] = int(v)
+		}
+		room, err := d.addRoom(pos[0], pos[1], pos[2])
+		if err != nil {
+			return err
+		}
+		flags, err := r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		room.goal = flags&1 != 0
+		switches, err := binary.ReadUvarint(r)
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if switches>>uint(switchNum) != 0 {
+			return fmt.Errorf("core: invalid switches in room (%d, %d, %d)", room.x, room.y, room.z)
+		}
+		for j := range room.switches {
+			room.switches[j] = (switches>>uint(j))&1 != 0
+		}
+		var oneWay byte
+		if 3 <= version {
+			b, err := r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			if b>>6 != 0 || (b&7)|(b>>3) != b&7 || (b&7)&^(flags>>1) != 0 {
+				return fmt.Errorf("core: invalid one-way passages in room (%d, %d, %d)", room.x, room.y, room.z)
+			}
+			oneWay = b
+		}
+		for j, dir := range encodedDirs {
+			if flags&(1<<uint(j+1)) == 0 {
+				continue
+			}
+			bs := make([]byte, (2*switchNum+7)/8)
+			if _, err := io.ReadFull(r, bs); err != nil {
+				return unexpectedEOF(err)
+			}
+			p := newPassage(switchNum)
+			for k := range p.switches {
+				t := passageSwitchType((bs[k/4] >> uint(2*(k%4))) & 3)
+				if passageSwitchTypeNeedTrue < t {
+					return fmt.Errorf("core: invalid requirement in room (%d, %d, %d)", room.x, room.y, room.z)
+				}
+				p.switches[k] = t
+			}
+			if oneWay&(1<<uint(j)) != 0 {
+				p.oneWay = true
+				p.oneWayDir = dir
+				if oneWay&(1<<uint(j+3)) != 0 {
+					p.oneWayDir = dir.opposite()
+				}
+			}
+			room.dirs[dir] = p
+		}
+	}
+	if r.Len() != 0 {
+		return errors.New("core: extra data after the field")
+	}
+	f2, err := d.field()
+	if err != nil {
+		return err
+	}
+	*f = *f2
+	return nil
+}
+
+func writeUvarint(w *bytes.Buffer, v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	w.Write(buf[:n])
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// fieldDecoder builds a field from decoded rooms and validates it.
+type fieldDecoder struct {
+	f *Field
+}
+
+func newFieldDecoder(width, height, depth, switches int) (*fieldDecoder, error) {
+	if width < 1 || MaxFieldSize < width || height < 1 || MaxFieldSize < height || depth < 1 || MaxFieldSize < depth {
+		return nil, fmt.Errorf("core: invalid field size: %d x %d x %d", width, height, depth)
+	}
+	if switches < 0 || MaxSwitches < switches {
+		return nil, fmt.Errorf("core: invalid number of switches: %d", switches)
+	}
+	return &fieldDecoder{
+		f: &Field{
+			rooms:    make([]*room, width*(height+1)*depth),
+			width:    width,
+			height:   height,
+			depth:    depth,
+			switches: switches,
+		},
+	}, nil
+}
+
+func (d *fieldDecoder) contains(x, y, z int) bool {
+	f := d.f
+	// The row at y = height is for the goal.
+	return 0 <= x && x < f.width && 0 <= y && y <= f.height && 0 <= z && z < f.depth
+}
+
+func (d *fieldDecoder) addRoom(x, y, z int) (*room, error) {
+	f := d.f
+	if !d.contains(x, y, z) {
+		return nil, fmt.Errorf("core: room (%d, %d, %d) is out of the field", x, y, z)
+	}
+	if f.rooms[f.index(x, y, z)] != nil {
+		return nil, fmt.Errorf("core: duplicated room (%d, %d, %d)", x, y, z)
+	}
+	r := f.newRoom(x, y, z)
+	f.rooms[f.index(x, y, z)] = r
+	return r, nil
+}
+
+// field connects the passages to the neighbor rooms and returns the field.
+func (d *fieldDecoder) field() (*Field, error) {
+	f := d.f
+	if f.rooms[f.index(0, 0, 0)] == nil {
+		return nil, errors.New("core: no start room at (0, 0, 0)")
+	}
+	goal := false
+	for _, r := range f.rooms {
+		if r == nil {
+			continue
+		}
+		if r.goal {
+			goal = true
+		}
+		for _, dir := range encodedDirs {
+			p := r.dirs[dir]
+			if p == nil {
+				continue
+			}
+			nx, ny, nz := r.x, r.y, r.z
+			switch dir {
+			case DirRight:
+				nx++
+			case DirDown:
+				ny++
+			case DirDownstairs:
+				nz++
+			}
+			if !d.contains(nx, ny, nz) || f.rooms[f.index(nx, ny, nz)] == nil {
+				return nil, fmt.Errorf("core: passage %s from room (%d, %d, %d) leads to no room", dirNames[dir], r.x, r.y, r.z)
+			}
+			f.rooms[f.index(nx, ny, nz)].dirs[dir.opposite()] = p
+		}
+	}
+	if !goal {
+		return nil, errors.New("core: no goal room")
+	}
+	return f, nil
+}
@@ -0,0 +1,302 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+// testFields returns generated fields with one-way passages and stairs, and a field loaded from a text level.
+func testFields(t *testing.T) map[string]*core.Field {
+	t.Helper()
+	fields := map[string]*core.Field{}
+	config := core.DefaultGeneratorConfig(3)
+	config.OneWayRatio = 0.5
+	config.DeadEndDensity = 0.5
+	for seed := uint64(0); seed < 4; seed++ {
+		f, err := core.NewField(3, 3, 3, 3, seed, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fields[fmt.Sprintf("generated %d", seed)] = f
+	}
+	level, err := os.ReadFile("../../levels/tutorial.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields["tutorial"] = mustParseLevel(t, string(level))
+	fields["level"] = mustParseLevel(t, `@@>>[]<<[]*A
+^^    vv  []
+[]D![]DNA-GL
+=
+[]  [][]D!
+    []
+  []UPUP
+=
+      U![]
+`)
+	return fields
+}
+
+// compareTiles reports the cells whose tiles differ between f1 and f2 for every combination of the switch states.
+func compareTiles(t *testing.T, f1, f2 *core.Field) {
+	t.Helper()
+	w, h, d := f1.TileSize()
+	w2, h2, d2 := f2.TileSize()
+	if w != w2 || h != h2 || d != d2 {
+		t.Fatalf("TileSize(): got %d x %d x %d, want %d x %d x %d", w2, h2, d2, w, h, d)
+	}
+	if f1.Switches() != f2.Switches() {
+		t.Fatalf("Switches(): got %d, want %d", f2.Switches(), f1.Switches())
+	}
+	x1, y1, z1 := f1.Start()
+	x2, y2, z2 := f2.Start()
+	if x1 != x2 || y1 != y2 || z1 != z2 {
+		t.Errorf("Start(): got (%d, %d, %d), want (%d, %d, %d)", x2, y2, z2, x1, y1, z1)
+	}
+	states := make([]bool, f1.Switches())
+	for bits := 0; bits < 1<<uint(f1.Switches()); bits++ {
+		for i := range states {
+			states[i] = bits&(1<<uint(i)) != 0
+		}
+		for z := 0; z < d; z++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					t1, sw1 := f1.Tile(x, y, z, states)
+					t2, sw2 := f2.Tile(x, y, z, states)
+					if t1 != t2 || sw1 != sw2 {
+						t.Fatalf("Tile(%d, %d, %d, %v): got (%d, %d), want (%d, %d)", x, y, z, states, t2, sw2, t1, sw1)
+					}
+				}
+			}
+		}
+	}
+}
+
+// hasTiles reports whether f has one-way tiles and stairs with all switches off.
+func hasTiles(f *core.Field) (oneWay, stairs bool) {
+	w, h, d := f.TileSize()
+	states := make([]bool, f.Switches())
+	for z := 0; z < d; z++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				switch t, _ := f.Tile(x, y, z, states); {
+				case t.OneWay():
+					oneWay = true
+				case t == core.TileUpstairs || t == core.TileDownstairs:
+					stairs = true
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestTestFields(t *testing.T) {
+	oneWay, stairs := false, false
+	for _, f := range testFields(t) {
+		o, s := hasTiles(f)
+		oneWay = oneWay || o
+		stairs = stairs || s
+	}
+	if !oneWay || !stairs {
+		t.Errorf("the test fields must have one-way tiles and stairs: one-way: %t, stairs: %t", oneWay, stairs)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for name, f := range testFields(t) {
+		t.Run(name, func(t *testing.T) {
+			b, err := json.Marshal(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f2 core.Field
+			if err := json.Unmarshal(b, &f2); err != nil {
+				t.Fatal(err)
+			}
+			compareTiles(t, f, &f2)
+
+			// Encoding again must give the same result.
+			b2, err := json.Marshal(&f2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != string(b2) {
+				t.Errorf("got %s, want %s", b2, b)
+			}
+		})
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for name, f := range testFields(t) {
+		t.Run(name, func(t *testing.T) {
+			b, err := f.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f2 core.Field
+			if err := f2.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			compareTiles(t, f, &f2)
+
+			b2, err := f2.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != string(b2) {
+				t.Errorf("got %v, want %v", b2, b)
+			}
+		})
+	}
+}
+
+// unmarshalBinary decodes b and converts a panic to a test failure.
+func unmarshalBinary(t *testing.T, b []byte) (err error) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("UnmarshalBinary(%v) panicked: %v", b, r)
+		}
+	}()
+	var f core.Field
+	return f.UnmarshalBinary(b)
+}
+
+func unmarshalJSON(t *testing.T, b []byte) (err error) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("UnmarshalJSON(%s) panicked: %v", b, r)
+		}
+	}()
+	var f core.Field
+	return json.Unmarshal(b, &f)
+}
+
+func TestUnmarshalBinaryTruncated(t *testing.T) {
+	for name, f := range testFields(t) {
+		b, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < len(b); n++ {
+			if err := unmarshalBinary(t, b[:n]); err == nil {
+				t.Errorf("%s: %d of %d bytes: UnmarshalBinary must fail", name, n, len(b))
+			}
+		}
+		if err := unmarshalBinary(t, append(b, 0)); err == nil {
+			t.Errorf("%s: UnmarshalBinary with extra data must fail", name)
+		}
+	}
+}
+
+func TestUnmarshalBinaryCorrupted(t *testing.T) {
+	// A corrupted byte might still make a valid field, but must never panic.
+	for _, f := range testFields(t) {
+		b, err := f.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range b {
+			for _, v := range []byte{0, 1, 0x7f, 0x80, 0xff} {
+				b2 := append([]byte{}, b...)
+				b2[i] = v
+				_ = unmarshalBinary(t, b2)
+			}
+		}
+	}
+}
+
+// binaryField builds a binary field with the header values and the following bytes.
+func binaryField(version byte, header []uint64, rest ...byte) []byte {
+	b := []byte("SWFD")
+	b = append(b, version, 0)
+	for _, v := range header {
+		b = binary.AppendUvarint(b, v)
+	}
+	return append(b, rest...)
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic", []byte("SWFX\x03\x00")},
+		{"version 0", binaryField(0, []uint64{1, 1, 1, 0, 0})},
+		{"future version", binaryField(core.FieldFormatVersion+1, []uint64{1, 1, 1, 0, 0})},
+		{"kind", append([]byte("SWFD\x03\x07"), 0)},
+		{"zero width", binaryField(3, []uint64{0, 1, 1, 0, 0})},
+		{"too wide", binaryField(3, []uint64{core.MaxFieldSize + 1, 1, 1, 0, 0})},
+		{"too many switches", binaryField(3, []uint64{1, 1, 1, core.MaxSwitches + 1, 0})},
+		{"huge header", binaryField(3, []uint64{1 << 62, 1, 1, 0, 0})},
+		{"huge room count", binaryField(3, []uint64{1, 1, 1, 0, 1 << 40})},
+		{"room out of the field", binaryField(3, []uint64{1, 1, 1, 0, 1, 5, 0, 0}, 0, 0, 0)},
+		{"room position overflow", binaryField(3, []uint64{1, 1, 1, 0, 1, 1 << 62, 0, 0}, 0, 0, 0)},
+		{"switch out of range", binaryField(3, []uint64{1, 1, 1, 1, 1, 0, 0, 0}, 0, 0x02, 0)},
+		{"invalid requirement", binaryField(3, []uint64{2, 1, 1, 1, 1, 0, 0, 0}, 0x02, 0, 0, 0x03)},
+		{"one-way without passage", binaryField(3, []uint64{1, 1, 1, 0, 1, 0, 0, 0}, 0, 0, 0x01)},
+		{"invalid one-way bits", binaryField(3, []uint64{2, 1, 1, 0, 1, 0, 0, 0}, 0x02, 0, 0x40)},
+		{"text level length", append([]byte("SWFD\x03\x01"), 100, '@', '@')},
+		{"text level", append([]byte("SWFD\x03\x01"), 2, '@', '@')},
+	}
+	for _, tc := range testCases {
+		if err := unmarshalBinary(t, tc.data); err == nil {
+			t.Errorf("%s: UnmarshalBinary must fail", tc.name)
+		}
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"empty", ``},
+		{"truncated", `{"version": 3, "width": 1, "height": 1`},
+		{"version 0", `{"version": 0, "width": 1, "height": 1, "depth": 1}`},
+		{"future version", fmt.Sprintf(`{"version": %d, "width": 1, "height": 1, "depth": 1}`, core.FieldFormatVersion+1)},
+		{"zero width", `{"version": 3, "width": 0, "height": 1, "depth": 1}`},
+		{"too deep", fmt.Sprintf(`{"version": 3, "width": 1, "height": 1, "depth": %d}`, core.MaxFieldSize+1)},
+		{"negative switches", `{"version": 3, "width": 1, "height": 1, "depth": 1, "switches": -1}`},
+		{"too many switches", fmt.Sprintf(`{"version": 3, "width": 1, "height": 1, "depth": 1, "switches": %d}`, core.MaxSwitches+1)},
+		{"room out of the field", `{"version": 3, "width": 1, "height": 1, "depth": 1, "rooms": [{"x": 1, "y": 0, "z": 0}]}`},
+		{"negative room", `{"version": 3, "width": 1, "height": 1, "depth": 1, "rooms": [{"x": -1, "y": 0, "z": 0}]}`},
+		{"duplicated room", `{"version": 3, "width": 1, "height": 1, "depth": 1, "rooms": [{"x": 0, "y": 0, "z": 0}, {"x": 0, "y": 0, "z": 0}]}`},
+		{"switch out of range", `{"version": 3, "width": 1, "height": 1, "depth": 1, "switches": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "switches": "B"}]}`},
+		{"passage direction", `{"version": 3, "width": 2, "height": 1, "depth": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "passages": {"left": ""}}]}`},
+		{"requirement length", `{"version": 3, "width": 2, "height": 1, "depth": 1, "switches": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "passages": {"right": "--"}}]}`},
+		{"requirement", `{"version": 3, "width": 2, "height": 1, "depth": 1, "switches": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "passages": {"right": "x"}}]}`},
+		{"passage out of the field", `{"version": 3, "width": 1, "height": 1, "depth": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "passages": {"right": ""}}]}`},
+		{"one-way without passage", `{"version": 3, "width": 2, "height": 1, "depth": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "oneWay": {"right": "right"}}]}`},
+		{"one-way direction", `{"version": 3, "width": 2, "height": 1, "depth": 1, "rooms": [{"x": 0, "y": 0, "z": 0, "passages": {"right": ""}, "oneWay": {"right": "up"}}]}`},
+		{"text level", `{"version": 3, "level": "@@[]"}`},
+	}
+	for _, tc := range testCases {
+		if err := unmarshalJSON(t, []byte(tc.data)); err == nil {
+			t.Errorf("%s: UnmarshalJSON must fail", tc.name)
+		}
+	}
+}
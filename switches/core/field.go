@@ -0,0 +1,770 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core implements the rules of Switches. It doesn't depend on Ebiten.
+package core
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+)
+
+type Dir int
+
+const (
+	DirLeft Dir = iota
+	DirRight
+	DirUp
+	DirDown
+	DirUpstairs
+	DirDownstairs
+)
+
+func (d Dir) opposite() Dir {
+	switch d {
+	case DirRight:
+		return DirLeft
+	case DirLeft:
+		return DirRight
+	case DirDown:
+		return DirUp
+	case DirUp:
+		return DirDown
+	case DirDownstairs:
+		return DirUpstairs
+	case DirUpstairs:
+		return DirDownstairs
+	}
+	panic("not reach")
+}
+
+type passageSwitchType int
+
+const (
+	passageSwitchTypeDontCare passageSwitchType = iota
+	passageSwitchTypeNeedFalse
+	passageSwitchTypeNeedTrue
+)
+
+type passage struct {
+	switches []passageSwitchType
+
+	// oneWay reports whether the passage can be passed only in the direction oneWayDir.
+	oneWay    bool
+	oneWayDir Dir
+}
+
+func (p *passage) passable(d Dir) bool {
+	return !p.oneWay || p.oneWayDir == d
+}
+
+func newPassage(switches int) *passage {
+	return &passage{
+		switches: make([]passageSwitchType, switches),
+	}
+}
+
+func (p *passage) initRandomly(r *rand.Rand, switches int, switchBits int) {
+	for i := 0; i < switches; i++ {
+		if switches > 1 && r.IntN(switches) == 0 {
+			continue
+		}
+		if (switchBits>>uint(i))&1 == 0 {
+			p.switches[i] = passageSwitchTypeNeedFalse
+		} else {
+			p.switches[i] = passageSwitchTypeNeedTrue
+		}
+	}
+}
+
+func (p *passage) allow(switches int, switchBits int) {
+	for i := 0; i < switches; i++ {
+		if (switchBits>>uint(i))&1 == 0 {
+			if p.switches[i] == passageSwitchTypeNeedTrue {
+				p.switches[i] = passageSwitchTypeDontCare
+			}
+		} else {
+			if p.switches[i] == passageSwitchTypeNeedFalse {
+				p.switches[i] = passageSwitchTypeDontCare
+			}
+		}
+	}
+}
+
+func (p *passage) dontCareNum(switches int, switchBits int) int {
+	n := 0
+	for i := 0; i < switches; i++ {
+		if p.switches[i] == passageSwitchTypeDontCare {
+			n++
+			continue
+		}
+		if (switchBits>>uint(i))&1 == 0 {
+			if p.switches[i] == passageSwitchTypeNeedTrue {
+				n++
+			}
+		} else {
+			if p.switches[i] == passageSwitchTypeNeedFalse {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+type room struct {
+	x, y, z  int
+	dirs     [6]*passage
+	switches []bool
+	goal     bool
+}
+
+type Field struct {
+	rooms    []*room
+	width    int
+	height   int
+	depth    int
+	switches int
+	seed     uint64
+	rand     *rand.Rand
+
+	// grid is not nil when the field is loaded from a text level.
+	grid *tileGrid
+}
+
+const (
+	MaxFieldSize = 64
+	MaxSwitches  = 26
+)
+
+// NewField generates a field. The same seed and parameters always generate the same field regardless of
+// GeneratorConfig.Parallelism.
+// If config is nil, DefaultGeneratorConfig is used.
+func NewField(width, height, depth, switches int, seed uint64, config *GeneratorConfig) (*Field, error) {
+	return NewFieldContext(context.Background(), width, height, depth, switches, seed, config, nil)
+}
+
+// NewFieldContext is like NewField but stops generating when ctx is done.
+// If progress is not nil, NewFieldContext sends the progress to it without blocking.
+func NewFieldContext(ctx context.Context, width, height, depth, switches int, seed uint64, config *GeneratorConfig, progress chan<- GeneratorProgress) (*Field, error) {
+	if width < 1 || MaxFieldSize < width || height < 1 || MaxFieldSize < height || depth < 1 || MaxFieldSize < depth {
+		return nil, fmt.Errorf("core: invalid field size: %d x %d x %d", width, height, depth)
+	}
+	if switches < 0 || MaxSwitches < switches {
+		return nil, fmt.Errorf("core: invalid number of switches: %d", switches)
+	}
+	if config == nil {
+		config = DefaultGeneratorConfig(switches)
+	}
+	if err := config.Validate(width, height, depth, switches); err != nil {
+		return nil, err
+	}
+	f := &Field{
+		width:    width,
+		height:   height,
+		depth:    depth,
+		switches: switches,
+		seed:     seed,
+	}
+	return f.generate(ctx, config, progress)
+}
+
+// HasRooms reports whether the field consists of rooms.
+// A field loaded from a text level consists only of tiles: it has no rooms, and Width and Height return 0.
+func (f *Field) HasRooms() bool {
+	return f.grid == nil
+}
+
+// Width returns the number of rooms in a row.
+func (f *Field) Width() int {
+	return f.width
+}
+
+// Height returns the number of rooms in a column.
+func (f *Field) Height() int {
+	return f.height
+}
+
+func (f *Field) Depth() int {
+	return f.depth
+}
+
+func (f *Field) Seed() uint64 {
+	return f.seed
+}
+
+func (f *Field) Switches() int {
+	return f.switches
+}
+
+func (f *Field) index(x, y, z int) int {
+	return x + y*f.width + z*f.width*(f.height+1)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
+
+func (f *Field) newRoom(x, y, z int) *room {
+	r := &room{
+		x:        x,
+		y:        y,
+		z:        z,
+		switches: make([]bool, f.switches),
+	}
+	return r
+}
+
+func (f *Field) makeRoughStructure(ctx context.Context, config *GeneratorConfig, report func(rooms int)) (bool, error) {
+	f.rooms = make([]*room, f.width*(f.height+1)*f.depth)
+	type position struct {
+		X, Y, Z, SwitchBits int
+	}
+	start := position{0, 0, 0, 0}
+	goal := position{f.width - 1, f.height - 1, f.depth - 1, (1 << uint(f.switches)) - 1}
+	f.rooms[f.index(start.X, start.Y, start.Z)] = f.newRoom(start.X, start.Y, start.Z)
+	// switchBits records the switch states with which the walk is in each room.
+	switchBits := map[*room][]int{}
+	switchBits[f.rooms[f.index(start.X, start.Y, start.Z)]] = []int{start.SwitchBits}
+	current := start
+	rooms := 1
+	report(rooms)
+	continued := 0
+	moves := 0
+	for i := 0; current != goal || moves < config.MinPathLength; i++ {
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return false, err
+			}
+		}
+		// TODO: Calc candidate first!
+		if config.RetryThreshold < continued {
+			return false, nil
+		}
+		nx, ny, nz, ns := current.X, current.Y, current.Z, current.SwitchBits
+		var d Dir
+		changeSwitch := f.switches > 0 && f.rand.Float64() < config.SwitchChangeRatio
+		changedSwitch := 0
+		if changeSwitch {
+			changedSwitch = f.rand.IntN(f.switches)
+			ns ^= 1 << uint(changedSwitch)
+		} else {
+			d = Dir(f.rand.IntN(6))
+			switch d {
+			case DirRight:
+				nx = min(current.X+1, f.width-1)
+			case DirLeft:
+				nx = max(current.X-1, 0)
+			case DirDown:
+				ny = min(current.Y+1, f.height-1)
+			case DirUp:
+				ny = max(current.Y-1, 0)
+			case DirDownstairs:
+				nz = min(current.Z+1, f.depth-1)
+			case DirUpstairs:
+				nz = max(current.Z-1, 0)
+			}
+			if nx == current.X && ny == current.Y && nz == current.Z {
+				continue
+			}
+		}
+		prevRoom := f.rooms[f.index(current.X, current.Y, current.Z)]
+		if changeSwitch {
+			n := 0
+			for i, b := range prevRoom.switches {
+				if b || i == changedSwitch {
+					n++
+				}
+			}
+			if config.MaxSwitchesPerRoom < n {
+				continued++
+				continue
+			}
+			prevRoom.switches[changedSwitch] = true
+		} else {
+			if prevRoom.dirs[d] == nil {
+				p := newPassage(f.switches)
+				p.initRandomly(f.rand, f.switches, ns)
+				if f.rand.Float64() < config.OneWayRatio {
+					p.oneWay = true
+					p.oneWayDir = d
+				}
+				prevRoom.dirs[d] = p
+				nextRoom := f.rooms[f.index(nx, ny, nz)]
+				if nextRoom == nil {
+					nextRoom = f.newRoom(nx, ny, nz)
+					f.rooms[f.index(nx, ny, nz)] = nextRoom
+					rooms++
+					report(rooms)
+				}
+				nextRoom.dirs[d.opposite()] = p
+			} else {
+				p := prevRoom.dirs[d]
+				if !p.passable(d) {
+					continued++
+					continue
+				}
+				if config.MaxDontCares < p.dontCareNum(f.switches, ns) {
+					continued++
+					continue
+				}
+				p.allow(f.switches, ns)
+			}
+		}
+		continued = 0
+		if !changeSwitch {
+			moves++
+		}
+		current = position{nx, ny, nz, ns}
+		r := f.rooms[f.index(nx, ny, nz)]
+		switchBits[r] = append(switchBits[r], ns)
+	}
+	f.addDeadEnds(config, switchBits)
+	lastRoom := f.newRoom(f.width-1, f.height, f.depth-1)
+	lastRoom.goal = true
+	f.rooms[f.index(f.width-1, f.height, f.depth-1)] = lastRoom
+	lastPassage := newPassage(f.switches)
+	for i := 0; i < f.switches; i++ {
+		lastPassage.switches[i] = passageSwitchTypeNeedTrue
+	}
+	f.rooms[f.index(f.width-1, f.height-1, f.depth-1)].dirs[DirDown] = lastPassage
+	lastRoom.dirs[DirUp] = lastPassage
+	return true, nil
+}
+
+// addDeadEnds adds rooms that lead nowhere to the field.
+// A passage to a dead end can be passed with switch states in switchBits of the room.
+func (f *Field) addDeadEnds(config *GeneratorConfig, switchBits map[*room][]int) {
+	var rooms []*room
+	for _, r := range f.rooms {
+		if r != nil {
+			rooms = append(rooms, r)
+		}
+	}
+	n := int(config.DeadEndDensity * float64(len(rooms)))
+	for i := 0; i < n; i++ {
+		r := rooms[f.rand.IntN(len(rooms))]
+		d := Dir(f.rand.IntN(6))
+		nx, ny, nz := r.x, r.y, r.z
+		switch d {
+		case DirRight:
+			nx++
+		case DirLeft:
+			nx--
+		case DirDown:
+			ny++
+		case DirUp:
+			ny--
+		case DirDownstairs:
+			nz++
+		case DirUpstairs:
+			nz--
+		}
+		// The row at y = height is only for the goal.
+		if nx < 0 || f.width <= nx || ny < 0 || f.height <= ny || nz < 0 || f.depth <= nz {
+			continue
+		}
+		if f.rooms[f.index(nx, ny, nz)] != nil {
+			continue
+		}
+		bits := switchBits[r][f.rand.IntN(len(switchBits[r]))]
+		p := newPassage(f.switches)
+		p.initRandomly(f.rand, f.switches, bits)
+		r.dirs[d] = p
+		next := f.newRoom(nx, ny, nz)
+		switchBits[next] = []int{bits}
+		next.dirs[d.opposite()] = p
+		f.rooms[f.index(nx, ny, nz)] = next
+		rooms = append(rooms, next)
+	}
+}
+
+type Tile int
+
+const (
+	TileNone Tile = iota
+	TileRegular
+	TileDownstairs
+	TileUpstairs
+	TileOneWayLeft
+	TileOneWayRight
+	TileOneWayUp
+	TileOneWayDown
+	TileOneWayDownstairs
+	TileOneWayUpstairs
+	TileSwitch0
+	TileSwitch1
+	TileSwitchedTileValid
+	TileSwitchedTileInvalid
+	TileGoal
+)
+
+func (t Tile) OneWay() bool {
+	switch t {
+	case TileOneWayLeft:
+		return true
+	case TileOneWayRight:
+		return true
+	case TileOneWayUp:
+		return true
+	case TileOneWayDown:
+		return true
+	case TileOneWayDownstairs:
+		return true
+	case TileOneWayUpstairs:
+		return true
+	}
+	return false
+}
+
+// OneWayDir returns the direction of the arrow of a one-way tile on a floor.
+// OneWayDir returns false for the other tiles including one-way stairs.
+func (t Tile) OneWayDir() (Dir, bool) {
+	switch t {
+	case TileOneWayLeft:
+		return DirLeft, true
+	case TileOneWayRight:
+		return DirRight, true
+	case TileOneWayUp:
+		return DirUp, true
+	case TileOneWayDown:
+		return DirDown, true
+	}
+	return 0, false
+}
+
+func (t Tile) IsPassable() bool {
+	if t == TileNone {
+		return false
+	}
+	if t == TileSwitchedTileInvalid {
+		return false
+	}
+	return true
+}
+
+func (f *Field) Start() (int, int, int) {
+	if f.grid != nil {
+		return f.grid.startX, f.grid.startY, f.grid.startZ
+	}
+	_, h := f.RoomSize()
+	return 2, h - 1, 0
+}
+
+func (f *Field) RoomSize() (int, int) {
+	return 5 + 2*f.switches, 4 + f.switches
+}
+
+// RoomInfo describes a room.
+type RoomInfo struct {
+	X, Y, Z int
+	Goal    bool
+
+	// Switches are the indices of the switches in the room.
+	Switches []int
+
+	// Passages reports whether the room has a passage in each direction.
+	Passages [6]bool
+}
+
+// Room returns the room at the room position (x, y, z), or false if there is no room.
+// The goal room is at the row y = Height().
+func (f *Field) Room(x, y, z int) (*RoomInfo, bool) {
+	if f.grid != nil {
+		return nil, false
+	}
+	if x < 0 || f.width <= x || y < 0 || f.height < y || z < 0 || f.depth <= z {
+		return nil, false
+	}
+	r := f.rooms[f.index(x, y, z)]
+	if r == nil {
+		return nil, false
+	}
+	info := &RoomInfo{
+		X:    x,
+		Y:    y,
+		Z:    z,
+		Goal: r.goal,
+	}
+	for i, b := range r.switches {
+		if b {
+			info.Switches = append(info.Switches, i)
+		}
+	}
+	for d, p := range r.dirs {
+		info.Passages[d] = p != nil
+	}
+	return info, true
+}
+
+// RoomPosition returns the room position of the tile position (x, y).
+func (f *Field) RoomPosition(x, y int) (int, int) {
+	w, h := f.RoomSize()
+	return x / w, y / h
+}
+
+// RoomCenter returns the tile position of the center of the room at (x, y). The center of a room is always passable.
+func (f *Field) RoomCenter(x, y int) (int, int) {
+	w, h := f.RoomSize()
+	return x*w + 2, y*h + h - 1
+}
+
+func (f *Field) TileSize() (int, int, int) {
+	if f.grid != nil {
+		return f.grid.width, f.grid.height, f.grid.depth
+	}
+	w, h := f.RoomSize()
+	return f.width * w, (f.height + 1) * h, f.depth
+}
+
+func switchedTile(passageSwitchType passageSwitchType, state bool) Tile {
+	switch passageSwitchType {
+	case passageSwitchTypeDontCare:
+		return TileRegular
+	case passageSwitchTypeNeedFalse:
+		if state {
+			return TileSwitchedTileInvalid
+		} else {
+			return TileSwitchedTileValid
+		}
+	case passageSwitchTypeNeedTrue:
+		if state {
+			return TileSwitchedTileValid
+		} else {
+			return TileSwitchedTileInvalid
+		}
+	}
+	panic("not reach")
+}
+
+// passageTile returns the tile at the entrance of the passage p in the direction d.
+func passageTile(p *passage, d Dir) Tile {
+	if !p.oneWay {
+		switch d {
+		case DirUpstairs:
+			return TileUpstairs
+		case DirDownstairs:
+			return TileDownstairs
+		}
+		return TileRegular
+	}
+	switch p.oneWayDir {
+	case DirLeft:
+		return TileOneWayLeft
+	case DirRight:
+		return TileOneWayRight
+	case DirUp:
+		return TileOneWayUp
+	case DirDown:
+		return TileOneWayDown
+	case DirUpstairs:
+		if d == DirUpstairs {
+			return TileOneWayUpstairs
+		}
+	case DirDownstairs:
+		if d == DirDownstairs {
+			return TileOneWayDownstairs
+		}
+	}
+	// The landing of one-way stairs.
+	return TileRegular
+}
+
+func (f *Field) Tile(x, y, z int, switchStates []bool) (Tile, int) {
+	if f.grid != nil {
+		return f.grid.tile(x, y, z, switchStates)
+	}
+
+	// 7x5
+	//     ^^
+	// ST  []  ST
+	// BL  BL  BL
+	// []  []SW[]
+	// [][][][][]BL>>
+
+	// 9x6
+	//     ^^
+	// ST  []    ST
+	// BL  BL    BL
+	// BL  BL    BL
+	// []  []SWSW[]
+	// [][][][][][]BLBL>>
+
+	w, h := f.RoomSize()
+	rx, ry, rz := x/w, y/h, z
+	room := f.rooms[f.index(rx, ry, rz)]
+	if room == nil {
+		return TileNone, 0
+	}
+	mx := x % w
+	my := y % h
+	cx, cy := 2, h-1
+	if mx == cx && my == cy {
+		if room.goal {
+			return TileGoal, 0
+		}
+		return TileRegular, 0
+	}
+	hasUpstairsLeft := false
+	hasDownstairsLeft := false
+	hasUpstairsRight := false
+	hasDownstairsRight := false
+	hasSwitch := false
+	for i := 0; i < f.switches; i++ {
+		if room.switches[i] {
+			hasSwitch = true
+			break
+		}
+	}
+	if z%2 == 0 {
+		if room.dirs[DirUpstairs] != nil {
+			hasUpstairsLeft = true
+		}
+		if room.dirs[DirDownstairs] != nil {
+			hasDownstairsRight = true
+		}
+	}
+	if z%2 == 1 {
+		if room.dirs[DirDownstairs] != nil {
+			hasDownstairsLeft = true
+		}
+		if room.dirs[DirUpstairs] != nil {
+			hasUpstairsRight = true
+		}
+	}
+
+	if my == cy {
+		switch {
+		case mx < cx:
+			if hasDownstairsLeft || hasUpstairsLeft || room.dirs[DirLeft] != nil {
+				return TileRegular, 0
+			}
+		case cx < mx && mx <= cx+f.switches+1:
+			if hasDownstairsRight || hasUpstairsRight || room.dirs[DirRight] != nil || hasSwitch {
+				return TileRegular, 0
+			}
+		case cx+f.switches+1 < mx:
+			p := room.dirs[DirRight]
+			if p == nil {
+				return TileNone, 0
+			}
+			// The arrow of a one-way passage comes after the gates in its direction. Otherwise, the player could
+			// pass the arrow and then be stuck in front of a closed gate.
+			arrow, gate := w-1, cx+f.switches+2
+			if p.oneWay && p.oneWayDir == DirLeft {
+				arrow, gate = cx+f.switches+2, cx+f.switches+3
+			}
+			if mx == arrow {
+				return passageTile(p, DirRight), 0
+			}
+			i := mx - gate
+			return switchedTile(p.switches[i], switchStates[i]), i
+		}
+		return TileNone, 0
+	}
+	if my == cy-1 && cx+1 <= mx && mx <= cx+f.switches {
+		i := mx - (cx + 1)
+		if room.switches[i] {
+			tile := TileSwitch0
+			if switchStates[i] {
+				tile = TileSwitch1
+			}
+			return tile, i
+		}
+		return TileNone, 0
+	}
+	switch {
+	case mx == 0:
+		if my == 0 {
+			return TileNone, 0
+		}
+		if hasUpstairsLeft {
+			switch {
+			case my == 1:
+				return passageTile(room.dirs[DirUpstairs], DirUpstairs), 0
+			case 1 < my && my < f.switches+2:
+				p := room.dirs[DirUpstairs]
+				i := my - 2
+				return switchedTile(p.switches[i], switchStates[i]), i
+			}
+			return TileRegular, 0
+		}
+		if hasDownstairsLeft {
+			switch {
+			case my == 1:
+				return passageTile(room.dirs[DirDownstairs], DirDownstairs), 0
+			case 1 < my && my < f.switches+2:
+				p := room.dirs[DirDownstairs]
+				i := my - 2
+				return switchedTile(p.switches[i], switchStates[i]), i
+			}
+			return TileRegular, 0
+		}
+	case mx == cx:
+		p := room.dirs[DirUp]
+		if p == nil {
+			return TileNone, 0
+		}
+		// As with the passage to the right, the arrow comes after the gates.
+		arrow := 0
+		if p.oneWay && p.oneWayDir == DirDown {
+			arrow = f.switches + 2
+		}
+		switch {
+		case my == arrow:
+			return passageTile(p, DirUp), 0
+		case 1 < my && my < f.switches+2:
+			i := my - 2
+			return switchedTile(p.switches[i], switchStates[i]), i
+		}
+		return TileRegular, 0
+	case mx == 3+f.switches:
+		if my == 0 {
+			return TileNone, 0
+		}
+		if hasDownstairsRight {
+			switch {
+			case my == 1:
+				return passageTile(room.dirs[DirDownstairs], DirDownstairs), 0
+			case 1 < my && my < f.switches+2:
+				p := room.dirs[DirDownstairs]
+				i := my - 2
+				return switchedTile(p.switches[i], switchStates[i]), i
+			}
+			return TileRegular, 0
+		}
+		if hasUpstairsRight {
+			switch {
+			case my == 1:
+				return passageTile(room.dirs[DirUpstairs], DirUpstairs), 0
+			case 1 < my && my < f.switches+2:
+				p := room.dirs[DirUpstairs]
+				i := my - 2
+				return switchedTile(p.switches[i], switchStates[i]), i
+			}
+			return TileRegular, 0
+		}
+	}
+	return TileNone, 0
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func mustNewField(t testing.TB, width, height, depth, switches int, seed uint64) *core.Field {
+	t.Helper()
+	f, err := core.NewField(width, height, depth, switches, seed, nil)
+	if err != nil {
+		t.Fatalf("NewField(%d, %d, %d, %d, %d): %v", width, height, depth, switches, seed, err)
+	}
+	return f
+}
+
+func mustMarshalBinary(t testing.TB, f *core.Field) []byte {
+	t.Helper()
+	b, err := f.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewFieldSameSeed(t *testing.T) {
+	for seed := uint64(0); seed < 8; seed++ {
+		f1 := mustNewField(t, 4, 4, 4, 4, seed)
+		f2 := mustNewField(t, 4, 4, 4, 4, seed)
+		if got := f1.Seed(); got != seed {
+			t.Errorf("Seed(): got %d, want %d", got, seed)
+		}
+		if !bytes.Equal(mustMarshalBinary(t, f1), mustMarshalBinary(t, f2)) {
+			t.Errorf("seed %d: the fields differ", seed)
+		}
+	}
+}
+
+func TestNewFieldDifferentSeeds(t *testing.T) {
+	fields := map[string]uint64{}
+	for seed := uint64(0); seed < 8; seed++ {
+		b := string(mustMarshalBinary(t, mustNewField(t, 4, 4, 4, 4, seed)))
+		if s, ok := fields[b]; ok {
+			t.Errorf("seeds %d and %d generated the same field", s, seed)
+		}
+		fields[b] = seed
+	}
+}
+
+func TestOneWayArrowAfterGates(t *testing.T) {
+	config := core.DefaultGeneratorConfig(3)
+	config.OneWayRatio = 0.5
+	deltas := map[core.Dir][2]int{
+		core.DirLeft:  {-1, 0},
+		core.DirRight: {1, 0},
+		core.DirUp:    {0, -1},
+		core.DirDown:  {0, 1},
+	}
+	arrows := map[core.Dir]int{}
+	for seed := uint64(0); seed < 20; seed++ {
+		f, err := core.NewField(4, 4, 2, 3, seed, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		states := make([]bool, f.Switches())
+		w, h, d := f.TileSize()
+		for z := 0; z < d; z++ {
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					tile, _ := f.Tile(x, y, z, states)
+					dir, ok := tile.OneWayDir()
+					if !ok {
+						continue
+					}
+					arrows[dir]++
+					// A gate right after the arrow could stop the player who can't go back.
+					for i := 1; i <= 2; i++ {
+						nx, ny := x+i*deltas[dir][0], y+i*deltas[dir][1]
+						switch t2, _ := f.Tile(nx, ny, z, states); t2 {
+						case core.TileSwitchedTileValid, core.TileSwitchedTileInvalid:
+							t.Errorf("seed %d: a gate at (%d, %d, %d) is after the arrow at (%d, %d, %d)", seed, nx, ny, z, x, y, z)
+						}
+					}
+				}
+			}
+		}
+	}
+	for dir := range deltas {
+		if arrows[dir] == 0 {
+			t.Errorf("no one-way arrow in the direction %v", dir)
+		}
+	}
+}
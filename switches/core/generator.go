@@ -0,0 +1,278 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// GeneratorConfig is the tuning of field generation.
+//
+// A field is generated by a random walk from the start room to the goal room. Each step of the walk either moves
+// to a neighbor room, making a passage if needed, or places a switch in the current room.
+type GeneratorConfig struct {
+	// SwitchChangeRatio is the probability that a step of the walk places a switch instead of moving.
+	SwitchChangeRatio float64
+
+	// MaxSwitchesPerRoom is the maximum number of switches in a room.
+	MaxSwitchesPerRoom int
+
+	// MaxDontCares is the maximum number of switches a passage doesn't care when the walk passes it again.
+	MaxDontCares int
+
+	// RetryThreshold is the number of successive rejected steps after which the walk is restarted.
+	RetryThreshold int
+
+	// OneWayRatio is the probability that a new passage is one-way.
+	OneWayRatio float64
+
+	// DeadEndDensity is the number of tries to add a dead-end room per room on the walk.
+	DeadEndDensity float64
+
+	// MinPathLength is the minimum number of moves between rooms in the walk.
+	MinPathLength int
+
+	// MaxAttempts is the maximum number of walks. If MaxAttempts is 0, the number of walks is not limited.
+	MaxAttempts int
+
+	// Parallelism is the number of goroutines to run walks. If Parallelism is 0, runtime.GOMAXPROCS(0) is used.
+	Parallelism int
+
+	// RejectInvalid makes the generator retry when ValidateField reports problems with a generated field.
+	// Fields too large to validate are not rejected.
+	RejectInvalid bool
+
+	// MinSolutionLength is the minimum number of moves of a solution with RejectInvalid.
+	MinSolutionLength int
+
+	// MinRating and MaxRating are the band of Rating.Score of generated fields. 0 means no bound.
+	// Fields too large to rate are not rejected.
+	MinRating float64
+	MaxRating float64
+}
+
+// ErrTooManyAttempts is returned when a field is not generated within GeneratorConfig.MaxAttempts.
+var ErrTooManyAttempts = errors.New("core: too many attempts to generate a field")
+
+// GeneratorProgress is the progress of field generation.
+type GeneratorProgress struct {
+	// Attempts is the number of started walks.
+	Attempts    int
+	MaxAttempts int
+
+	// Rooms is the number of rooms a running walk has visited.
+	Rooms    int
+	MaxRooms int
+}
+
+// DefaultGeneratorConfig returns the default config for fields with the given number of switches.
+func DefaultGeneratorConfig(switches int) *GeneratorConfig {
+	return &GeneratorConfig{
+		SwitchChangeRatio:  0.25,
+		MaxSwitchesPerRoom: max(1, switches/2),
+		MaxDontCares:       max(0, switches-2),
+		RetryThreshold:     10,
+		OneWayRatio:        0.125,
+		MaxAttempts:        10000,
+	}
+}
+
+// Validate returns an error if fields of the given size and number of switches can't be generated with the config.
+func (c *GeneratorConfig) Validate(width, height, depth, switches int) error {
+	// The walk doesn't enter the goal room.
+	rooms := width * height * depth
+	if c.SwitchChangeRatio < 0 || 1 <= c.SwitchChangeRatio {
+		return fmt.Errorf("core: switch change ratio must be in [0, 1): %g", c.SwitchChangeRatio)
+	}
+	if 0 < switches && c.SwitchChangeRatio == 0 {
+		return errors.New("core: switch change ratio must be positive to turn on the switches")
+	}
+	if c.MaxSwitchesPerRoom < 0 || (0 < switches && c.MaxSwitchesPerRoom < 1) || max(1, switches) < c.MaxSwitchesPerRoom {
+		return fmt.Errorf("core: max switches per room must be in [1, %d]: %d", max(1, switches), c.MaxSwitchesPerRoom)
+	}
+	if c.MaxSwitchesPerRoom*rooms < switches {
+		return fmt.Errorf("core: %d rooms with at most %d switches each can't have %d switches", rooms, c.MaxSwitchesPerRoom, switches)
+	}
+	if c.MaxDontCares < 0 || switches < c.MaxDontCares {
+		return fmt.Errorf("core: max don't-cares must be in [0, %d]: %d", switches, c.MaxDontCares)
+	}
+	if c.RetryThreshold < 1 {
+		return fmt.Errorf("core: retry threshold must be positive: %d", c.RetryThreshold)
+	}
+	if c.OneWayRatio < 0 || 1 <= c.OneWayRatio {
+		return fmt.Errorf("core: one-way ratio must be in [0, 1): %g", c.OneWayRatio)
+	}
+	if c.DeadEndDensity < 0 {
+		return fmt.Errorf("core: dead-end density must not be negative: %g", c.DeadEndDensity)
+	}
+	if c.MinPathLength < 0 {
+		return fmt.Errorf("core: min path length must not be negative: %d", c.MinPathLength)
+	}
+	// The walk can't move in a single room, and it would never end without counting the rejected steps. With more
+	// rooms, the walk can be as long as needed by going back and forth.
+	if rooms == 1 && 0 < c.MinPathLength {
+		return fmt.Errorf("core: min path length must be 0 for a single room: %d", c.MinPathLength)
+	}
+	if c.MaxAttempts < 0 {
+		return fmt.Errorf("core: max attempts must not be negative: %d", c.MaxAttempts)
+	}
+	if c.Parallelism < 0 {
+		return fmt.Errorf("core: parallelism must not be negative: %d", c.Parallelism)
+	}
+	if c.MinSolutionLength < 0 {
+		return fmt.Errorf("core: min solution length must not be negative: %d", c.MinSolutionLength)
+	}
+	if c.MinSolutionLength != 0 && !c.RejectInvalid {
+		return errors.New("core: min solution length requires rejecting invalid fields")
+	}
+	if c.MinRating < 0 || c.MaxRating < 0 || (c.MaxRating != 0 && c.MaxRating < c.MinRating) {
+		return fmt.Errorf("core: invalid rating band: [%g, %g]", c.MinRating, c.MaxRating)
+	}
+	return nil
+}
+
+// generate runs walks on copies of f in parallel. Each attempt has its own random stream derived from the seed.
+//
+// generate returns the successful field with the smallest attempt index instead of the first finished one,
+// so the result doesn't depend on the scheduling.
+func (f *Field) generate(ctx context.Context, config *GeneratorConfig, progress chan<- GeneratorProgress) (*Field, error) {
+	n := config.Parallelism
+	if n == 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	maxAttempts := int64(config.MaxAttempts)
+	if maxAttempts == 0 {
+		maxAttempts = math.MaxInt64
+	}
+
+	var (
+		next    atomic.Int64
+		found   atomic.Int64
+		m       sync.Mutex
+		result  *Field
+		lastErr error
+		wg      sync.WaitGroup
+	)
+	found.Store(math.MaxInt64)
+
+	fail := func(err error) {
+		m.Lock()
+		lastErr = err
+		m.Unlock()
+	}
+
+	report := func(rooms int) {
+		if progress == nil {
+			return
+		}
+		p := GeneratorProgress{
+			Attempts:    int(min64(next.Load(), maxAttempts)),
+			MaxAttempts: config.MaxAttempts,
+			Rooms:       rooms,
+			MaxRooms:    f.width * f.height * f.depth,
+		}
+		select {
+		case progress <- p:
+		default:
+		}
+	}
+
+	for j := 0; j < n; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := next.Add(1) - 1
+				// Attempts after a successful one are not needed.
+				if maxAttempts <= i || found.Load() <= i {
+					return
+				}
+				a := *f
+				a.rand = rand.New(rand.NewPCG(f.seed, uint64(i)))
+				ok, err := a.makeRoughStructure(ctx, config, report)
+				if err != nil {
+					fail(err)
+					return
+				}
+				if !ok {
+					continue
+				}
+				// Validating and rating a field can take long, so check the cancellation before and after them.
+				if config.RejectInvalid {
+					if err := ctx.Err(); err != nil {
+						fail(err)
+						return
+					}
+					if r, err := ValidateField(&a, config.MinSolutionLength); err == nil && !r.OK() {
+						continue
+					}
+				}
+				if config.MinRating != 0 || config.MaxRating != 0 {
+					if err := ctx.Err(); err != nil {
+						fail(err)
+						return
+					}
+					if r, err := RateField(&a); err == nil && !config.inRatingBand(r.Score) {
+						continue
+					}
+				}
+				if err := ctx.Err(); err != nil {
+					fail(err)
+					return
+				}
+				m.Lock()
+				if i < found.Load() {
+					found.Store(i)
+					result = &a
+				}
+				m.Unlock()
+				return
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	if result == nil {
+		return nil, fmt.Errorf("%w: %d attempts", ErrTooManyAttempts, config.MaxAttempts)
+	}
+	return result, nil
+}
+
+func (c *GeneratorConfig) inRatingBand(score float64) bool {
+	if c.MinRating != 0 && score < c.MinRating {
+		return false
+	}
+	if c.MaxRating != 0 && c.MaxRating < score {
+		return false
+	}
+	return true
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
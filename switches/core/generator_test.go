@@ -0,0 +1,152 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func TestGeneratorConfigValidate(t *testing.T) {
+	for _, d := range core.Difficulties {
+		config := d.Config
+		if config == nil {
+			config = core.DefaultGeneratorConfig(d.Switches)
+		}
+		if err := config.Validate(d.Width, d.Height, d.Depth, d.Switches); err != nil {
+			t.Errorf("%s: %v", d.Name, err)
+		}
+	}
+
+	testCases := []struct {
+		name                           string
+		width, height, depth, switches int
+		modify                         func(c *core.GeneratorConfig)
+		valid                          bool
+	}{
+		{"default", 1, 1, 1, 1, func(c *core.GeneratorConfig) {}, true},
+		{"too few rooms for the switches", 1, 1, 2, 5, func(c *core.GeneratorConfig) {}, false},
+		{"enough rooms for the switches", 1, 1, 3, 5, func(c *core.GeneratorConfig) {}, true},
+		{"path in a single room", 1, 1, 1, 1, func(c *core.GeneratorConfig) { c.MinPathLength = 1 }, false},
+		{"long path in two rooms", 2, 1, 1, 1, func(c *core.GeneratorConfig) { c.MinPathLength = 100 }, true},
+		{"negative path", 2, 1, 1, 1, func(c *core.GeneratorConfig) { c.MinPathLength = -1 }, false},
+		{"no switch change", 2, 2, 2, 2, func(c *core.GeneratorConfig) { c.SwitchChangeRatio = 0 }, false},
+		{"too many switches per room", 2, 2, 2, 2, func(c *core.GeneratorConfig) { c.MaxSwitchesPerRoom = 3 }, false},
+		{"min solution length without rejection", 2, 2, 2, 2, func(c *core.GeneratorConfig) { c.MinSolutionLength = 10 }, false},
+		{"inverted rating band", 2, 2, 2, 2, func(c *core.GeneratorConfig) { c.MinRating, c.MaxRating = 50, 20 }, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := core.DefaultGeneratorConfig(tc.switches)
+			tc.modify(config)
+			err := config.Validate(tc.width, tc.height, tc.depth, tc.switches)
+			if tc.valid && err != nil {
+				t.Errorf("got %v, want nil", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("got nil, want an error")
+			}
+		})
+	}
+}
+
+func TestNewFieldParallelism(t *testing.T) {
+	// A low retry threshold makes most walks fail, so the result depends on which attempt succeeds first.
+	newConfig := func(parallelism int) *core.GeneratorConfig {
+		config := core.DefaultGeneratorConfig(4)
+		config.RetryThreshold = 1
+		config.DeadEndDensity = 0.5
+		config.Parallelism = parallelism
+		return config
+	}
+	for seed := uint64(0); seed < 4; seed++ {
+		var want []byte
+		for _, parallelism := range []int{1, 4, 16} {
+			f, err := core.NewField(4, 4, 4, 4, seed, newConfig(parallelism))
+			if err != nil {
+				t.Fatalf("seed %d, parallelism %d: %v", seed, parallelism, err)
+			}
+			b := mustMarshalBinary(t, f)
+			if want == nil {
+				want = b
+				continue
+			}
+			if !bytes.Equal(b, want) {
+				t.Errorf("seed %d: the field with parallelism %d differs from the one with parallelism 1", seed, parallelism)
+			}
+		}
+	}
+}
+
+func TestNewFieldCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := core.NewFieldContext(ctx, 4, 4, 4, 4, 1, nil, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewFieldCanceledWhileRating(t *testing.T) {
+	// No field is in the rating band, so the generation goes on until it is canceled.
+	config := core.DefaultGeneratorConfig(4)
+	config.MinRating = 1000
+	config.MaxAttempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	progress := make(chan core.GeneratorProgress, 1)
+	go func() {
+		<-progress
+		cancel()
+	}()
+	if _, err := core.NewFieldContext(ctx, 4, 4, 4, 4, 1, config, progress); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestNewFieldTooManyAttempts(t *testing.T) {
+	config := core.DefaultGeneratorConfig(4)
+	config.MinRating = 1000
+	config.MaxAttempts = 1
+	if _, err := core.NewField(4, 4, 4, 4, 1, config); !errors.Is(err, core.ErrTooManyAttempts) {
+		t.Errorf("got %v, want %v", err, core.ErrTooManyAttempts)
+	}
+}
+
+func TestNewFieldProgress(t *testing.T) {
+	config := core.DefaultGeneratorConfig(4)
+	config.MaxAttempts = 100
+	// The channel is large enough to receive all the progress, as NewFieldContext doesn't block on it.
+	progress := make(chan core.GeneratorProgress, 1<<16)
+	if _, err := core.NewFieldContext(context.Background(), 4, 4, 4, 4, 1, config, progress); err != nil {
+		t.Fatal(err)
+	}
+	close(progress)
+	n := 0
+	for p := range progress {
+		n++
+		if p.Attempts < 1 || config.MaxAttempts < p.Attempts || p.MaxAttempts != config.MaxAttempts {
+			t.Errorf("invalid attempts: %+v", p)
+		}
+		if p.Rooms < 1 || p.MaxRooms < p.Rooms || p.MaxRooms != 4*4*4 {
+			t.Errorf("invalid rooms: %+v", p)
+		}
+	}
+	if n == 0 {
+		t.Errorf("no progress is reported")
+	}
+}
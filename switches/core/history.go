@@ -0,0 +1,51 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+// History records states of a play to undo and redo moves.
+type History struct {
+	undo []*State
+	redo []*State
+}
+
+// Push records the state s before a move. Push discards the states to redo.
+func (h *History) Push(s *State) {
+	h.undo = append(h.undo, s.Clone())
+	h.redo = nil
+}
+
+// Undo returns the state before the last move, given the current state.
+// Undo returns false if there is nothing to undo.
+func (h *History) Undo(current *State) (*State, bool) {
+	if len(h.undo) == 0 {
+		return nil, false
+	}
+	s := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, current.Clone())
+	return s.Clone(), true
+}
+
+// Redo returns the state after the last undone move, given the current state.
+// Redo returns false if there is nothing to redo.
+func (h *History) Redo(current *State) (*State, bool) {
+	if len(h.redo) == 0 {
+		return nil, false
+	}
+	s := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, current.Clone())
+	return s.Clone(), true
+}
@@ -0,0 +1,121 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+const historyLevel = `@@[]*A[]A+GL
+`
+
+// step pushes the state to the history and steps it like the game does.
+func step(t *testing.T, f *core.Field, h *core.History, s *core.State, d core.Dir) {
+	t.Helper()
+	h.Push(s)
+	if !s.Step(f, d) {
+		t.Fatalf("move %v is not possible", d)
+	}
+}
+
+func TestHistoryUndoRedo(t *testing.T) {
+	f := mustParseLevel(t, historyLevel)
+	var h core.History
+	s := core.NewState(f)
+	var states []*core.State
+	for i := 0; i < 5; i++ {
+		states = append(states, s.Clone())
+		step(t, f, &h, s, core.DirRight)
+	}
+	if !s.IsGoal(f) {
+		t.Fatalf("the goal is not reached")
+	}
+	last := s.Clone()
+
+	for i := len(states) - 1; 0 <= i; i-- {
+		var ok bool
+		s, ok = h.Undo(s)
+		if !ok {
+			t.Fatalf("Undo %d failed", i)
+		}
+		if !reflect.DeepEqual(s, states[i]) {
+			t.Errorf("Undo %d: got %+v, want %+v", i, s, states[i])
+		}
+	}
+	if _, ok := h.Undo(s); ok {
+		t.Errorf("Undo at the start must fail")
+	}
+	// Undo restores the switch states and the counts too.
+	if s.SwitchStates[0] || s.Steps != 0 || s.Flips != 0 {
+		t.Errorf("the start state is not restored: %+v", s)
+	}
+
+	for i := 1; i < len(states); i++ {
+		var ok bool
+		s, ok = h.Redo(s)
+		if !ok {
+			t.Fatalf("Redo %d failed", i)
+		}
+		if !reflect.DeepEqual(s, states[i]) {
+			t.Errorf("Redo %d: got %+v, want %+v", i, s, states[i])
+		}
+	}
+	s, _ = h.Redo(s)
+	if !reflect.DeepEqual(s, last) {
+		t.Errorf("Redo: got %+v, want %+v", s, last)
+	}
+	if _, ok := h.Redo(s); ok {
+		t.Errorf("Redo at the end must fail")
+	}
+}
+
+func TestHistoryPushDiscardsRedo(t *testing.T) {
+	f := mustParseLevel(t, historyLevel)
+	var h core.History
+	s := core.NewState(f)
+	step(t, f, &h, s, core.DirRight)
+	step(t, f, &h, s, core.DirRight)
+	s, _ = h.Undo(s)
+	step(t, f, &h, s, core.DirLeft)
+	if _, ok := h.Redo(s); ok {
+		t.Errorf("Redo after a new move must fail")
+	}
+	s, _ = h.Undo(s)
+	s, _ = h.Undo(s)
+	if !reflect.DeepEqual(s, core.NewState(f)) {
+		t.Errorf("got %+v, want the start state", s)
+	}
+}
+
+func TestHistoryStatesAreIndependent(t *testing.T) {
+	f := mustParseLevel(t, historyLevel)
+	var h core.History
+	s := core.NewState(f)
+	step(t, f, &h, s, core.DirRight)
+	step(t, f, &h, s, core.DirRight)
+	// Modifying the current state must not change the recorded states.
+	s.SwitchStates[0] = false
+	s, _ = h.Undo(s)
+	if s.SwitchStates[0] {
+		t.Errorf("switch A must be off before stepping on it")
+	}
+	s, _ = h.Redo(s)
+	if s.SwitchStates[0] {
+		t.Errorf("Redo must return the state given to Undo")
+	}
+}
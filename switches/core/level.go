@@ -0,0 +1,316 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A text level is a hand-authored field. A text level has one grid per floor, from the ground floor to the bottom.
+// Floors are separated by a line consisting only of '='. Lines starting with '#' are comments.
+//
+// Each tile is two characters:
+//
+//	"  "  none
+//	"[]"  regular
+//	"@@"  the start (a regular tile)
+//	"GL"  the goal
+//	"UP"  upstairs
+//	"DN"  downstairs
+//	"U!"  one-way upstairs
+//	"D!"  one-way downstairs
+//	"<<"  one-way left
+//	">>"  one-way right
+//	"^^"  one-way up
+//	"vv"  one-way down
+//	"*A"  switch A (A to Z)
+//	"A+"  a tile passable when switch A is on
+//	"A-"  a tile passable when switch A is off
+//
+// Rows can be shorter than others; the missing tiles are none. The number of switches is decided by the last switch
+// letter used. Stairs must lead to a tile on the floor above or below, which must not be one-way stairs, a gate or
+// a switch.
+//
+// For example, this is a level with two floors and one switch:
+//
+//	@@[][]*A
+//	    []
+//	    DN
+//	=
+//
+//
+//	    UP[]A+GL
+
+// LevelError is an error in a text level.
+type LevelError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *LevelError) Error() string {
+	if e.Column == 0 {
+		return fmt.Sprintf("core: line %d: %s", e.Line, e.Msg)
+	}
+	return fmt.Sprintf("core: line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// maxLevelTiles is the maximum number of tiles in a text level.
+const maxLevelTiles = 1 << 22
+
+type gridTile struct {
+	// tile is TileSwitch0 for a switch and TileSwitchedTileValid for a tile that depends on a switch.
+	tile Tile
+	sw   int
+	need passageSwitchType
+}
+
+type tileGrid struct {
+	width  int
+	height int
+	depth  int
+	tiles  []gridTile
+
+	startX int
+	startY int
+	startZ int
+}
+
+func (g *tileGrid) at(x, y, z int) *gridTile {
+	if x < 0 || g.width <= x || y < 0 || g.height <= y || z < 0 || g.depth <= z {
+		return nil
+	}
+	return &g.tiles[x+y*g.width+z*g.width*g.height]
+}
+
+func (g *tileGrid) tile(x, y, z int, switchStates []bool) (Tile, int) {
+	t := g.at(x, y, z)
+	if t == nil {
+		return TileNone, 0
+	}
+	switch t.tile {
+	case TileSwitch0:
+		if switchStates[t.sw] {
+			return TileSwitch1, t.sw
+		}
+		return TileSwitch0, t.sw
+	case TileSwitchedTileValid:
+		return switchedTile(t.need, switchStates[t.sw]), t.sw
+	}
+	return t.tile, 0
+}
+
+var levelTiles = map[string]Tile{
+	"  ": TileNone,
+	"[]": TileRegular,
+	"@@": TileRegular,
+	"GL": TileGoal,
+	"UP": TileUpstairs,
+	"DN": TileDownstairs,
+	"U!": TileOneWayUpstairs,
+	"D!": TileOneWayDownstairs,
+	"<<": TileOneWayLeft,
+	">>": TileOneWayRight,
+	"^^": TileOneWayUp,
+	"vv": TileOneWayDown,
+}
+
+type levelPos struct {
+	line   int
+	column int
+}
+
+// ParseLevel parses a text level.
+func ParseLevel(r io.Reader) (*Field, error) {
+	type levelRow struct {
+		line int
+		text string
+	}
+	var floors [][]levelRow
+	floors = append(floors, nil)
+	s := bufio.NewScanner(r)
+	line := 0
+	for s.Scan() {
+		line++
+		text := strings.TrimRight(s.Text(), "\r")
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		if text != "" && strings.Trim(text, "=") == "" {
+			floors = append(floors, nil)
+			continue
+		}
+		if len(text)%2 != 0 {
+			return nil, &LevelError{Line: line, Msg: "a row must have an even number of characters"}
+		}
+		floors[len(floors)-1] = append(floors[len(floors)-1], levelRow{line, text})
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	g := &tileGrid{
+		depth: len(floors),
+	}
+	for _, rows := range floors {
+		g.height = max(g.height, len(rows))
+		for _, r := range rows {
+			g.width = max(g.width, len(r.text)/2)
+		}
+	}
+	if g.width == 0 || g.height == 0 {
+		return nil, &LevelError{Line: line, Msg: "the level is empty"}
+	}
+	if maxLevelTiles < g.width*g.height*g.depth {
+		return nil, &LevelError{Line: line, Msg: "the level is too large"}
+	}
+	g.tiles = make([]gridTile, g.width*g.height*g.depth)
+
+	positions := map[[3]int]levelPos{}
+	var start *levelPos
+	goal := false
+	switches := 0
+	for z, rows := range floors {
+		for y, r := range rows {
+			for x := 0; x < len(r.text)/2; x++ {
+				pos := levelPos{r.line, 2*x + 1}
+				str := r.text[2*x : 2*x+2]
+				t := g.at(x, y, z)
+				if lt, ok := levelTiles[str]; ok {
+					t.tile = lt
+				} else if str[0] == '*' && 'A' <= str[1] && str[1] <= 'Z' {
+					t.tile = TileSwitch0
+					t.sw = int(str[1] - 'A')
+				} else if 'A' <= str[0] && str[0] <= 'Z' && (str[1] == '+' || str[1] == '-') {
+					t.tile = TileSwitchedTileValid
+					t.sw = int(str[0] - 'A')
+					t.need = passageSwitchTypeNeedTrue
+					if str[1] == '-' {
+						t.need = passageSwitchTypeNeedFalse
+					}
+				} else {
+					return nil, &LevelError{Line: pos.line, Column: pos.column, Msg: fmt.Sprintf("unknown tile %q", str)}
+				}
+				switch {
+				case str == "@@":
+					if start != nil {
+						return nil, &LevelError{Line: pos.line, Column: pos.column, Msg: fmt.Sprintf("another start is at line %d, column %d", start.line, start.column)}
+					}
+					start = &pos
+					g.startX, g.startY, g.startZ = x, y, z
+				case t.tile == TileGoal:
+					goal = true
+				case t.tile == TileSwitch0 || t.tile == TileSwitchedTileValid:
+					switches = max(switches, t.sw+1)
+				}
+				positions[[3]int{x, y, z}] = pos
+			}
+		}
+	}
+	if start == nil {
+		return nil, &LevelError{Line: line, Msg: "no start tile (@@)"}
+	}
+	if !goal {
+		return nil, &LevelError{Line: line, Msg: "no goal tile (GL)"}
+	}
+	for z := 0; z < g.depth; z++ {
+		for y := 0; y < g.height; y++ {
+			for x := 0; x < g.width; x++ {
+				dz := 0
+				switch g.at(x, y, z).tile {
+				case TileUpstairs, TileOneWayUpstairs:
+					dz = -1
+				case TileDownstairs, TileOneWayDownstairs:
+					dz = 1
+				default:
+					continue
+				}
+				// The player would be stuck on one-way stairs or a closed gate, and landing on a switch doesn't
+				// toggle it.
+				msg := ""
+				switch t := g.at(x, y, z+dz); {
+				case t == nil || t.tile == TileNone:
+					msg = "the stairs lead to no tile"
+				case t.tile == TileOneWayUpstairs || t.tile == TileOneWayDownstairs:
+					msg = "the stairs lead to one-way stairs"
+				case t.tile == TileSwitchedTileValid:
+					msg = "the stairs lead to a gate"
+				case t.tile == TileSwitch0:
+					msg = "the stairs lead to a switch"
+				default:
+					continue
+				}
+				pos := positions[[3]int{x, y, z}]
+				return nil, &LevelError{Line: pos.line, Column: pos.column, Msg: msg}
+			}
+		}
+	}
+
+	return &Field{
+		depth:    g.depth,
+		switches: switches,
+		grid:     g,
+	}, nil
+}
+
+// WriteLevel writes the field as a text level.
+func (f *Field) WriteLevel(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width, height, depth := f.TileSize()
+	sx, sy, sz := f.Start()
+	noSwitches := make([]bool, f.switches)
+	for z := 0; z < depth; z++ {
+		if 0 < z {
+			if _, err := bw.WriteString("=\n"); err != nil {
+				return err
+			}
+		}
+		for y := 0; y < height; y++ {
+			var row strings.Builder
+			for x := 0; x < width; x++ {
+				if x == sx && y == sy && z == sz {
+					row.WriteString("@@")
+					continue
+				}
+				row.WriteString(levelTileString(f.Tile(x, y, z, noSwitches)))
+			}
+			if _, err := bw.WriteString(strings.TrimRight(row.String(), " ") + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// levelTileString returns the text of the tile when all the switches are off.
+func levelTileString(t Tile, sw int) string {
+	switch t {
+	case TileSwitch0:
+		return "*" + string(rune('A'+sw))
+	case TileSwitchedTileValid:
+		return string(rune('A'+sw)) + "-"
+	case TileSwitchedTileInvalid:
+		return string(rune('A'+sw)) + "+"
+	}
+	for str, lt := range levelTiles {
+		if lt == t && str != "@@" {
+			return str
+		}
+	}
+	panic("not reach")
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func TestParseLevelErrors(t *testing.T) {
+	testCases := []struct {
+		name   string
+		level  string
+		line   int
+		column int
+	}{
+		{"odd row", "@@[]GL\n[]x\n", 2, 0},
+		{"empty", "", 0, 0},
+		{"only comments", "# a comment\n# another comment\n", 2, 0},
+		{"unknown tile", "@@[]xxGL\n", 1, 5},
+		{"lowercase switch", "@@*aGL\n", 1, 3},
+		{"another start", "@@\n[]@@GL\n", 2, 3},
+		{"no start", "[]GL\n[]\n", 2, 0},
+		{"no goal", "@@[]\n", 1, 0},
+		{"stairs to no floor", "@@[]DNGL\n", 1, 5},
+		{"stairs to no tile", "@@[]DNGL\n=\n[]\n", 1, 5},
+		{"stairs to one-way stairs", "@@DNGL\n=\n  D!\n", 1, 3},
+		{"stairs to a gate", "@@DNGL*A\n=\n  A+\n", 1, 3},
+		{"stairs to a switch", "@@DNGL\n=\n  *A\n", 1, 3},
+		{"line numbers count comments and separators", "# comment\n@@GL\n=\n    UP\n", 4, 5},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := core.ParseLevel(strings.NewReader(tc.level))
+			var lerr *core.LevelError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("got %v, want a LevelError", err)
+			}
+			if lerr.Line != tc.line || lerr.Column != tc.column {
+				t.Errorf("got line %d, column %d (%v), want line %d, column %d", lerr.Line, lerr.Column, err, tc.line, tc.column)
+			}
+		})
+	}
+}
+
+func TestLevelErrorMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err  *core.LevelError
+		want string
+	}{
+		{&core.LevelError{Line: 3, Column: 5, Msg: "unknown tile"}, "core: line 3, column 5: unknown tile"},
+		{&core.LevelError{Line: 3, Msg: "no goal tile (GL)"}, "core: line 3: no goal tile (GL)"},
+	} {
+		if got := tc.err.Error(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	f := mustParseLevel(t, "# comment\r\n@@[]*BA+\r\n  DN  GL\r\n=\r\n\r\n  UP\r\n")
+	if got, want := f.Switches(), 2; got != want {
+		t.Errorf("Switches(): got %d, want %d", got, want)
+	}
+	if f.HasRooms() {
+		t.Errorf("HasRooms(): got true, want false")
+	}
+	w, h, d := f.TileSize()
+	if w != 4 || h != 2 || d != 2 {
+		t.Errorf("TileSize(): got %d x %d x %d, want 4 x 2 x 2", w, h, d)
+	}
+	for _, tc := range []struct {
+		x, y, z int
+		want    core.Tile
+	}{
+		{0, 0, 0, core.TileRegular},
+		{2, 0, 0, core.TileSwitch0},
+		{3, 0, 0, core.TileSwitchedTileInvalid},
+		{1, 1, 0, core.TileDownstairs},
+		{3, 1, 0, core.TileGoal},
+		{1, 1, 1, core.TileUpstairs},
+		{0, 1, 1, core.TileNone},
+	} {
+		if got, _ := f.Tile(tc.x, tc.y, tc.z, []bool{false, false}); got != tc.want {
+			t.Errorf("Tile(%d, %d, %d): got %d, want %d", tc.x, tc.y, tc.z, got, tc.want)
+		}
+	}
+
+	var b strings.Builder
+	if err := f.WriteLevel(&b); err != nil {
+		t.Fatal(err)
+	}
+	compareTiles(t, f, mustParseLevel(t, b.String()))
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"github.com/hajimehoshi/switches/switches/internal/pathfinding"
+)
+
+// pathStairsCost is the extra cost of going upstairs or downstairs.
+// Changing floors is disorienting, so a path takes a few extra moves rather than stairs.
+const pathStairsCost = 4
+
+// pathGraph is the graph of the tiles the player can walk through without changing the switch states.
+type pathGraph struct {
+	field    *Field
+	state    State
+	passable func(x, y, z int) bool
+	goal     pathfinding.Pos
+}
+
+func (g *pathGraph) Size() (int, int, int) {
+	return g.field.TileSize()
+}
+
+func (g *pathGraph) StairsCost() int {
+	return pathStairsCost
+}
+
+func (g *pathGraph) AppendEdges(edges []pathfinding.Edge, p pathfinding.Pos) []pathfinding.Edge {
+	for _, d := range []Dir{DirLeft, DirRight, DirUp, DirDown} {
+		g.state.X, g.state.Y, g.state.Z = p.X, p.Y, p.Z
+		nx, ny, ok := g.state.Next(g.field, d)
+		if !ok {
+			continue
+		}
+		if g.passable != nil && !g.passable(nx, ny, p.Z) {
+			continue
+		}
+		// Move changes the floor at stairs.
+		_, sw := g.state.Move(g.field, d)
+		to := pathfinding.Pos{X: g.state.X, Y: g.state.Y, Z: g.state.Z}
+		if sw && to != g.goal {
+			continue
+		}
+		cost := 1
+		if to.Z != p.Z {
+			cost += pathStairsCost
+		}
+		edges = append(edges, pathfinding.Edge{
+			To:   to,
+			Cost: cost,
+			Move: int(d),
+		})
+	}
+	return edges
+}
+
+// CalcPath3D returns the moves from the state s to the tile (goalX, goalY, goalZ), going upstairs and downstairs if needed.
+// As the switch states don't change on the way, the path never steps on a switch except the goal.
+// passable reports whether the player may enter the tile at (x, y, z) in addition to the field's rules. passable can be nil.
+// CalcPath3D returns nil if there is no path.
+func CalcPath3D(f *Field, s *State, passable func(x, y, z int) bool, goalX, goalY, goalZ int) []Dir {
+	w, h, d := f.TileSize()
+	if goalX < 0 || w <= goalX || goalY < 0 || h <= goalY || goalZ < 0 || d <= goalZ {
+		return nil
+	}
+	if passable != nil && !passable(goalX, goalY, goalZ) {
+		return nil
+	}
+	goal := pathfinding.Pos{X: goalX, Y: goalY, Z: goalZ}
+	start := pathfinding.Pos{X: s.X, Y: s.Y, Z: s.Z}
+	if start == goal {
+		return nil
+	}
+	// Entering stairs moves the player to the other floor.
+	switch t, _ := f.Tile(goalX, goalY, goalZ, s.SwitchStates); t {
+	case TileUpstairs, TileOneWayUpstairs:
+		goal.Z--
+	case TileDownstairs, TileOneWayDownstairs:
+		goal.Z++
+	}
+	g := &pathGraph{
+		field:    f,
+		state:    State{SwitchStates: s.SwitchStates},
+		passable: passable,
+		goal:     goal,
+	}
+	edges, ok := pathfinding.Search(g, start, goal)
+	if !ok || len(edges) == 0 {
+		return nil
+	}
+	path := make([]Dir, len(edges))
+	for i, e := range edges {
+		path[i] = Dir(e.Move)
+	}
+	return path
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+const pathTestLevel = `@@*A[]DN
+[][][][]
+[][][][]
+=
+GL[][]UP
+`
+
+func TestCalcPath3D(t *testing.T) {
+	f := mustParseLevel(t, pathTestLevel)
+	block := func(bx, by, bz int) func(x, y, z int) bool {
+		return func(x, y, z int) bool {
+			return x != bx || y != by || z != bz
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		goal     [3]int
+		passable func(x, y, z int) bool
+		// n is the length of the path, or 0 if there is no path.
+		n int
+		// end is the position after the path, which differs from the goal at stairs.
+		end    [3]int
+		toggle bool
+	}{
+		{"same floor", [3]int{3, 1, 0}, nil, 4, [3]int{3, 1, 0}, false},
+		{"downstairs", [3]int{0, 0, 1}, nil, 8, [3]int{0, 0, 1}, false},
+		{"goal on stairs", [3]int{3, 0, 0}, nil, 5, [3]int{3, 0, 1}, false},
+		{"around switch", [3]int{2, 0, 0}, nil, 4, [3]int{2, 0, 0}, false},
+		{"switch as goal", [3]int{1, 0, 0}, nil, 1, [3]int{1, 0, 0}, true},
+		{"detour", [3]int{2, 0, 0}, block(1, 1, 0), 6, [3]int{2, 0, 0}, false},
+		{"blocked", [3]int{2, 0, 0}, block(0, 1, 0), 0, [3]int{}, false},
+		{"blocked goal", [3]int{2, 0, 0}, block(2, 0, 0), 0, [3]int{}, false},
+		{"start", [3]int{0, 0, 0}, nil, 0, [3]int{}, false},
+		{"none tile", [3]int{1, 1, 1}, nil, 0, [3]int{}, false},
+		{"out of field", [3]int{4, 0, 0}, nil, 0, [3]int{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := core.CalcPath3D(f, core.NewState(f), tc.passable, tc.goal[0], tc.goal[1], tc.goal[2])
+			if got := len(path); got != tc.n {
+				t.Fatalf("len(path): got %d, want %d (%v)", got, tc.n, path)
+			}
+			if tc.n == 0 {
+				if path != nil {
+					t.Errorf("got %v, want nil", path)
+				}
+				return
+			}
+			s := replay(t, f, path)
+			if got := [3]int{s.X, s.Y, s.Z}; got != tc.end {
+				t.Errorf("end: got %v, want %v", got, tc.end)
+			}
+			if got := s.SwitchStates[0]; got != tc.toggle {
+				t.Errorf("switch A: got %t, want %t", got, tc.toggle)
+			}
+		})
+	}
+}
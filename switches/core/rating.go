@@ -0,0 +1,97 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"math"
+)
+
+// Rating is an estimate of how hard a field is.
+type Rating struct {
+	// Solution is a shortest solution from the start.
+	Solution *Solution
+
+	// FloorChanges is the number of times the solution goes upstairs or downstairs.
+	FloorChanges int
+
+	// ReachableStates is the number of the states the player can reach from the start.
+	ReachableStates int
+
+	// Branching is the average number of moves per step of the solution that leave the solution.
+	Branching float64
+
+	// Score is a weighted sum of the values above. Scores are meaningful only relative to each other.
+	Score float64
+}
+
+// RateField rates the field.
+// RateField returns ErrUnsolvable if the field is unsolvable, or ErrTooLarge if the field is too large to search.
+func RateField(f *Field) (*Rating, error) {
+	sv, err := newSolver(f, maxSolverStates)
+	if err != nil {
+		return nil, err
+	}
+	start := NewState(f)
+	goal := sv.search(start, true)
+	if goal == -1 {
+		return nil, ErrUnsolvable
+	}
+	r := &Rating{
+		Solution:        sv.solution(goal),
+		ReachableStates: sv.visited,
+	}
+
+	// Collect the states on the solution.
+	path := map[int32]struct{}{}
+	for id := goal; ; id = sv.parents[id] {
+		path[id] = struct{}{}
+		if sv.parents[id] == id {
+			break
+		}
+	}
+
+	s := start.Clone()
+	branches := 0
+	for _, m := range r.Solution.Moves {
+		for _, d := range []Dir{DirLeft, DirRight, DirUp, DirDown} {
+			if d == m {
+				continue
+			}
+			s2 := s.Clone()
+			if !s2.Step(f, d) {
+				continue
+			}
+			if _, ok := path[sv.encode(s2)]; ok {
+				continue
+			}
+			branches++
+		}
+		z := s.Z
+		s.Step(f, m)
+		if s.Z != z {
+			r.FloorChanges++
+		}
+	}
+	if n := r.Solution.Len(); n > 0 {
+		r.Branching = float64(branches) / float64(n)
+	}
+
+	r.Score = 0.05*float64(r.Solution.Len()) +
+		1.0*float64(r.Solution.Flips) +
+		0.5*float64(r.FloorChanges) +
+		2.0*math.Log2(float64(r.ReachableStates)) +
+		4.0*r.Branching
+	return r, nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func mustRateField(t *testing.T, f *core.Field) *core.Rating {
+	t.Helper()
+	r, err := core.RateField(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestRateField(t *testing.T) {
+	// The solution goes downstairs and comes back upstairs onto the goal.
+	// The reachable states are the 5 tiles other than the none tiles.
+	r := mustRateField(t, mustParseLevel(t, "@@DN  GL\n=\n  UP[]UP\n"))
+	if got, want := r.Solution.Len(), 3; got != want {
+		t.Errorf("Solution.Len(): got %d, want %d", got, want)
+	}
+	if got, want := r.FloorChanges, 2; got != want {
+		t.Errorf("FloorChanges: got %d, want %d", got, want)
+	}
+	if got, want := r.ReachableStates, 5; got != want {
+		t.Errorf("ReachableStates: got %d, want %d", got, want)
+	}
+}
+
+func TestRateFieldHarder(t *testing.T) {
+	levels := []string{
+		"@@[][]GL\n",
+		"@@[]A+GL\n  *A\n",
+		"@@[]A+B-GL\n  *A*B\n",
+	}
+	var prev *core.Rating
+	for _, level := range levels {
+		r := mustRateField(t, mustParseLevel(t, level))
+		if prev != nil && r.Score <= prev.Score {
+			t.Errorf("%q: Score: got %g, want more than %g", level, r.Score, prev.Score)
+		}
+		prev = r
+	}
+}
+
+func TestRateFieldUnsolvable(t *testing.T) {
+	if _, err := core.RateField(mustParseLevel(t, "@@A+GL\n")); err != core.ErrUnsolvable {
+		t.Errorf("got %v, want %v", err, core.ErrUnsolvable)
+	}
+}
+
+func TestNewFieldRatingBand(t *testing.T) {
+	var scores []float64
+	for seed := uint64(0); seed < 16; seed++ {
+		scores = append(scores, mustRateField(t, mustNewField(t, 3, 3, 2, 3, seed)).Score)
+	}
+	sort.Float64s(scores)
+	median := scores[len(scores)/2]
+
+	for _, tc := range []struct {
+		name string
+		min  float64
+		max  float64
+	}{
+		{"min", median, 0},
+		{"max", 0, median},
+	} {
+		config := core.DefaultGeneratorConfig(3)
+		config.MinRating = tc.min
+		config.MaxRating = tc.max
+		for seed := uint64(0); seed < 8; seed++ {
+			f, err := core.NewField(3, 3, 2, 3, seed, config)
+			if err != nil {
+				t.Fatalf("%s: seed %d: %v", tc.name, seed, err)
+			}
+			s := mustRateField(t, f).Score
+			if (tc.min != 0 && s < tc.min) || (tc.max != 0 && tc.max < s) {
+				t.Errorf("%s: seed %d: Score %g is out of [%g, %g]", tc.name, seed, s, tc.min, tc.max)
+			}
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"errors"
+)
+
+var (
+	ErrUnsolvable = errors.New("core: the field is unsolvable")
+	ErrTooLarge   = errors.New("core: the field is too large to solve")
+
+	// ErrInvalidState is returned when the state to solve from is not on a tile of the field or doesn't have
+	// the field's number of switches.
+	ErrInvalidState = errors.New("core: the state is invalid for the field")
+)
+
+// maxSolverStates is the maximum number of states a solver can hold.
+// A state takes 4 bytes in the parents and at most 4 bytes in the queue, so the states take at most 128 MiB.
+const maxSolverStates = 1 << 24
+
+// maxSolverTiles is the maximum number of tiles of a field a solver can index.
+const maxSolverTiles = 1 << 22
+
+type Solution struct {
+	Moves []Dir
+	Flips int
+}
+
+func (s *Solution) Len() int {
+	return len(s.Moves)
+}
+
+// Solve returns a shortest sequence of moves that takes the player from the state s to the goal.
+// The moves are what State.Step accepts.
+// Solve returns ErrInvalidState if s is not a state in the field.
+func Solve(f *Field, s *State) (*Solution, error) {
+	return SolveLimit(f, s, maxSolverStates)
+}
+
+// SolveLimit is like Solve but returns ErrTooLarge if the field has more than maxStates states to search.
+// maxStates larger than the limit of Solve is reduced to it.
+func SolveLimit(f *Field, s *State, maxStates int) (*Solution, error) {
+	sv, err := newSolver(f, min(maxStates, maxSolverStates))
+	if err != nil {
+		return nil, err
+	}
+	return sv.solve(s)
+}
+
+// solver searches the space of the player's position and the switch states in breadth-first order.
+//
+// A solver state is represented as an index: a compact position index shifted by the number of switches,
+// or-ed with the switch bits.
+type solver struct {
+	field *Field
+
+	// indices maps a tile position to a compact position index, or -1 if the tile is never passable.
+	indices []int32
+
+	// positions maps a compact position index to a tile position.
+	positions [][3]int
+
+	// parents maps a state to its parent state. -1 means the state is not visited yet.
+	parents []int32
+
+	// visited is the number of the visited states in the last search.
+	visited int
+
+	// disabledSwitches is the mask of the switches the player must not toggle.
+	disabledSwitches int32
+
+	state *State
+}
+
+// newSolver returns a solver for the field f, or ErrTooLarge if f has more than maxStates states.
+func newSolver(f *Field, maxStates int) (*solver, error) {
+	w, h, d := f.TileSize()
+	// Check the size before allocating anything, as a decoded field can be arbitrarily large.
+	if maxSolverTiles < w*h*d {
+		return nil, ErrTooLarge
+	}
+	// Each room has at least one passable tile.
+	rooms := 0
+	for _, r := range f.rooms {
+		if r != nil {
+			rooms++
+		}
+	}
+	if maxStates < rooms<<uint(f.switches) {
+		return nil, ErrTooLarge
+	}
+	sv := &solver{
+		field:   f,
+		indices: make([]int32, w*h*d),
+	}
+	noSwitches := make([]bool, f.switches)
+	for z := 0; z < d; z++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := x + y*w + z*w*h
+				if t, _ := f.Tile(x, y, z, noSwitches); t == TileNone {
+					sv.indices[i] = -1
+					continue
+				}
+				if maxStates < (len(sv.positions)+1)<<uint(f.switches) {
+					return nil, ErrTooLarge
+				}
+				sv.indices[i] = int32(len(sv.positions))
+				sv.positions = append(sv.positions, [3]int{x, y, z})
+			}
+		}
+	}
+	sv.parents = make([]int32, len(sv.positions)<<uint(f.switches))
+	sv.state = &State{
+		SwitchStates: make([]bool, f.switches),
+	}
+	return sv, nil
+}
+
+// encode returns the solver state of s, or -1 if s is out of the field.
+func (sv *solver) encode(s *State) int32 {
+	w, h, d := sv.field.TileSize()
+	if s.X < 0 || w <= s.X || s.Y < 0 || h <= s.Y || s.Z < 0 || d <= s.Z {
+		return -1
+	}
+	idx := sv.indices[s.X+s.Y*w+s.Z*w*h]
+	if idx == -1 {
+		return -1
+	}
+	bits := int32(0)
+	for i, b := range s.SwitchStates {
+		if b {
+			bits |= 1 << uint(i)
+		}
+	}
+	return idx<<uint(sv.field.switches) | bits
+}
+
+func (sv *solver) decode(id int32, s *State) {
+	pos := sv.positions[id>>uint(sv.field.switches)]
+	s.X, s.Y, s.Z = pos[0], pos[1], pos[2]
+	for i := range s.SwitchStates {
+		s.SwitchStates[i] = (id>>uint(i))&1 != 0
+	}
+}
+
+// search visits the states reachable from s in breadth-first order.
+// search returns the first goal state it reaches, or -1 if the goal is unreachable.
+// If all is false, search stops at the goal. Otherwise, search visits all the reachable states.
+func (sv *solver) search(s *State, all bool) int32 {
+	for i := range sv.parents {
+		sv.parents[i] = -1
+	}
+	start := sv.encode(s)
+	sv.parents[start] = start
+	sv.visited = 1
+	goal := int32(-1)
+	if s.IsGoal(sv.field) {
+		if !all {
+			return start
+		}
+		goal = start
+	}
+	current := []int32{start}
+	for 0 < len(current) {
+		next := []int32{}
+		for _, id := range current {
+			for _, d := range []Dir{DirLeft, DirRight, DirUp, DirDown} {
+				sv.decode(id, sv.state)
+				if !sv.state.Step(sv.field, d) {
+					continue
+				}
+				nid := sv.encode(sv.state)
+				if nid == -1 || sv.parents[nid] != -1 {
+					continue
+				}
+				if (nid^id)&sv.disabledSwitches != 0 {
+					continue
+				}
+				sv.parents[nid] = id
+				sv.visited++
+				if goal == -1 && sv.state.IsGoal(sv.field) {
+					if !all {
+						return nid
+					}
+					goal = nid
+				}
+				next = append(next, nid)
+			}
+		}
+		current = next
+	}
+	return goal
+}
+
+// reached reports whether the last search visited the tile position at the compact position index idx.
+func (sv *solver) reached(idx int32) bool {
+	n := int32(1) << uint(sv.field.switches)
+	for id := idx * n; id < (idx+1)*n; id++ {
+		if sv.parents[id] != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+func (sv *solver) solve(s *State) (*Solution, error) {
+	if len(s.SwitchStates) != sv.field.switches || sv.encode(s) == -1 {
+		return nil, ErrInvalidState
+	}
+	goal := sv.search(s, false)
+	if goal == -1 {
+		return nil, ErrUnsolvable
+	}
+	return sv.solution(goal), nil
+}
+
+// solution returns the moves from the start to the goal state in the last search.
+func (sv *solver) solution(goal int32) *Solution {
+	sol := &Solution{}
+	from := &State{SwitchStates: make([]bool, sv.field.switches)}
+	to := &State{SwitchStates: make([]bool, sv.field.switches)}
+	for id := goal; sv.parents[id] != id; id = sv.parents[id] {
+		sv.decode(sv.parents[id], from)
+		sv.decode(id, to)
+		d, ok := sv.dirBetween(from, to)
+		if !ok {
+			panic("not reach")
+		}
+		sol.Moves = append(sol.Moves, d)
+		if sv.parents[id]&(1<<uint(sv.field.switches)-1) != id&(1<<uint(sv.field.switches)-1) {
+			sol.Flips++
+		}
+	}
+	for i, j := 0, len(sol.Moves)-1; i < j; i, j = i+1, j-1 {
+		sol.Moves[i], sol.Moves[j] = sol.Moves[j], sol.Moves[i]
+	}
+	return sol
+}
+
+// dirBetween returns the direction that takes the player from the state from to the state to.
+func (sv *solver) dirBetween(from, to *State) (Dir, bool) {
+	for _, d := range []Dir{DirLeft, DirRight, DirUp, DirDown} {
+		s := from.Clone()
+		if !s.Step(sv.field, d) {
+			continue
+		}
+		if sv.encode(s) == sv.encode(to) {
+			return d, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,240 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func mustParseLevel(t testing.TB, level string) *core.Field {
+	t.Helper()
+	f, err := core.ParseLevel(strings.NewReader(level))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// replay steps the moves from the start and returns the last state.
+func replay(t testing.TB, f *core.Field, moves []core.Dir) *core.State {
+	t.Helper()
+	s := core.NewState(f)
+	for i, d := range moves {
+		if !s.Step(f, d) {
+			t.Fatalf("move %d (%v) is not possible", i, d)
+		}
+	}
+	return s
+}
+
+// shortestLength returns the length of a shortest solution by a plain breadth-first search over states.
+func shortestLength(f *core.Field) int {
+	key := func(s *core.State) string {
+		return fmt.Sprint(s.X, s.Y, s.Z, s.SwitchStates)
+	}
+	start := core.NewState(f)
+	if start.IsGoal(f) {
+		return 0
+	}
+	visited := map[string]struct{}{key(start): {}}
+	current := []*core.State{start}
+	for n := 1; 0 < len(current); n++ {
+		var next []*core.State
+		for _, s := range current {
+			for _, d := range []core.Dir{core.DirLeft, core.DirRight, core.DirUp, core.DirDown} {
+				s2 := s.Clone()
+				if !s2.Step(f, d) {
+					continue
+				}
+				if s2.IsGoal(f) {
+					return n
+				}
+				if _, ok := visited[key(s2)]; ok {
+					continue
+				}
+				visited[key(s2)] = struct{}{}
+				next = append(next, s2)
+			}
+		}
+		current = next
+	}
+	return -1
+}
+
+func TestSolveLevels(t *testing.T) {
+	testCases := []struct {
+		name  string
+		level string
+		want  int
+	}{
+		{
+			name:  "straight",
+			level: "@@[][]GL\n",
+			want:  3,
+		},
+		{
+			name: "shortcut",
+			level: `@@[][][]GL
+[]    []
+[][][][]
+`,
+			want: 4,
+		},
+		{
+			name: "switch",
+			level: `@@[]A+GL
+  *A
+`,
+			want: 5,
+		},
+		{
+			name: "one-way along",
+			level: `@@>>GL
+`,
+			want: 2,
+		},
+		{
+			name: "one-way against",
+			level: `@@<<GL
+[]  []
+[][][]
+`,
+			want: 6,
+		},
+		{
+			name: "downstairs",
+			level: `@@[]DN
+=
+GL[]UP
+`,
+			want: 4,
+		},
+		{
+			name: "one-way upstairs",
+			level: `GL[][]
+=
+@@[]U!
+`,
+			want: 4,
+		},
+		{
+			name: "one-way downstairs",
+			level: `@@[]D!  GL
+=
+    [][]UP
+`,
+			want: 4,
+		},
+		{
+			name: "no way back from one-way downstairs",
+			level: `GL[]D![]@@
+=
+    []
+`,
+			want: -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := mustParseLevel(t, tc.level)
+			sol, err := core.Solve(f, core.NewState(f))
+			if tc.want == -1 {
+				if err != core.ErrUnsolvable {
+					t.Fatalf("Solve: got %v, want %v", err, core.ErrUnsolvable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sol.Len(); got != tc.want {
+				t.Errorf("Len(): got %d, want %d", got, tc.want)
+			}
+			if s := replay(t, f, sol.Moves); !s.IsGoal(f) {
+				t.Errorf("the solution doesn't reach the goal")
+			}
+		})
+	}
+}
+
+func TestSolveShortest(t *testing.T) {
+	// The generated fields have one-way passages and stairs.
+	for seed := uint64(0); seed < 10; seed++ {
+		f := mustNewField(t, 3, 3, 2, 3, seed)
+		sol, err := core.Solve(f, core.NewState(f))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		s := replay(t, f, sol.Moves)
+		if !s.IsGoal(f) {
+			t.Errorf("seed %d: the solution doesn't reach the goal", seed)
+		}
+		if s.Flips != sol.Flips {
+			t.Errorf("seed %d: Flips: got %d, want %d", seed, sol.Flips, s.Flips)
+		}
+		if got, want := sol.Len(), shortestLength(f); got != want {
+			t.Errorf("seed %d: Len(): got %d, want %d", seed, got, want)
+		}
+	}
+}
+
+func TestSolveTooLarge(t *testing.T) {
+	var level strings.Builder
+	level.WriteString("@@")
+	for c := 'A'; c <= 'Z'; c++ {
+		level.WriteString("*" + string(c))
+	}
+	level.WriteString("GL\n")
+	f := mustParseLevel(t, level.String())
+	if _, err := core.Solve(f, core.NewState(f)); err != core.ErrTooLarge {
+		t.Errorf("Solve: got %v, want %v", err, core.ErrTooLarge)
+	}
+}
+
+func TestSolveLimit(t *testing.T) {
+	// The level has 5 tiles and 1 switch, so it has 10 states.
+	f := mustParseLevel(t, "@@[]A+GL\n  *A\n")
+	if _, err := core.SolveLimit(f, core.NewState(f), 9); err != core.ErrTooLarge {
+		t.Errorf("SolveLimit with 9 states: got %v, want %v", err, core.ErrTooLarge)
+	}
+	sol, err := core.SolveLimit(f, core.NewState(f), 10)
+	if err != nil {
+		t.Fatalf("SolveLimit with 10 states: %v", err)
+	}
+	if got, want := sol.Len(), 5; got != want {
+		t.Errorf("Len(): got %d, want %d", got, want)
+	}
+}
+
+func TestSolveInvalidState(t *testing.T) {
+	f := mustParseLevel(t, "@@[]A+GL\n  *A\n")
+	for _, tc := range []struct {
+		name  string
+		state *core.State
+	}{
+		{"no tile", &core.State{X: 0, Y: 1, SwitchStates: []bool{false}}},
+		{"out of the field", &core.State{X: 10, Y: 0, SwitchStates: []bool{false}}},
+		{"too few switch states", &core.State{}},
+		{"too many switch states", &core.State{SwitchStates: []bool{false, false}}},
+	} {
+		if _, err := core.Solve(f, tc.state); err != core.ErrInvalidState {
+			t.Errorf("%s: got %v, want %v", tc.name, err, core.ErrInvalidState)
+		}
+	}
+}
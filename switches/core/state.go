@@ -0,0 +1,137 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+// State is the state of a play on a field: the player's position, the switch states and the counts of the player's actions.
+type State struct {
+	X            int
+	Y            int
+	Z            int
+	Dir          Dir
+	SwitchStates []bool
+	Steps        int
+	Flips        int
+}
+
+func NewState(f *Field) *State {
+	x, y, z := f.Start()
+	return &State{
+		X:            x,
+		Y:            y,
+		Z:            z,
+		SwitchStates: make([]bool, f.switches),
+	}
+}
+
+func (s *State) Clone() *State {
+	s2 := *s
+	s2.SwitchStates = make([]bool, len(s.SwitchStates))
+	copy(s2.SwitchStates, s.SwitchStates)
+	return &s2
+}
+
+func (s *State) Tile(f *Field) (Tile, int) {
+	return f.Tile(s.X, s.Y, s.Z, s.SwitchStates)
+}
+
+func (s *State) IsGoal(f *Field) bool {
+	t, _ := s.Tile(f)
+	return t == TileGoal
+}
+
+// Next returns the position the player would move to in the direction d.
+// Next returns false if the player can't move in the direction.
+// On a one-way tile, the player can move only in the direction of the arrow,
+// and the player can't step on a one-way tile against its arrow.
+func (s *State) Next(f *Field, d Dir) (int, int, bool) {
+	if t, _ := s.Tile(f); t.OneWay() {
+		if od, ok := t.OneWayDir(); !ok || od != d {
+			return 0, 0, false
+		}
+	}
+	w, h, _ := f.TileSize()
+	nx, ny := s.X, s.Y
+	switch d {
+	case DirLeft:
+		nx = max(s.X-1, 0)
+	case DirRight:
+		nx = min(s.X+1, w-1)
+	case DirUp:
+		ny = max(s.Y-1, 0)
+	case DirDown:
+		ny = min(s.Y+1, h-1)
+	}
+	if s.X == nx && s.Y == ny {
+		return 0, 0, false
+	}
+	t, _ := f.Tile(nx, ny, s.Z, s.SwitchStates)
+	if !t.IsPassable() {
+		return 0, 0, false
+	}
+	if od, ok := t.OneWayDir(); ok && od == d.opposite() {
+		return 0, 0, false
+	}
+	return nx, ny, true
+}
+
+// Move moves the player by one tile in the direction d without checking the destination.
+// If the player steps on a switch, Move returns the switch index and true.
+// The switch is not toggled by Move; call Toggle to toggle it.
+func (s *State) Move(f *Field, d Dir) (int, bool) {
+	s.Dir = d
+	s.Steps++
+	switch d {
+	case DirLeft:
+		s.X--
+	case DirRight:
+		s.X++
+	case DirUp:
+		s.Y--
+	case DirDown:
+		s.Y++
+	}
+	switch t, sw := s.Tile(f); t {
+	case TileUpstairs:
+		fallthrough
+	case TileOneWayUpstairs:
+		s.Z -= 1
+	case TileDownstairs:
+		fallthrough
+	case TileOneWayDownstairs:
+		s.Z += 1
+	case TileSwitch0:
+		fallthrough
+	case TileSwitch1:
+		return sw, true
+	}
+	return 0, false
+}
+
+func (s *State) Toggle(sw int) {
+	s.SwitchStates[sw] = !s.SwitchStates[sw]
+	s.Flips++
+}
+
+// Step moves the player in the direction d and toggles the switch the player steps on, if any.
+// Step reports whether the player moved.
+func (s *State) Step(f *Field, d Dir) bool {
+	if _, _, ok := s.Next(f, d); !ok {
+		return false
+	}
+	if sw, ok := s.Move(f, d); ok {
+		s.Toggle(sw)
+	}
+	return true
+}
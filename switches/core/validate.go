@@ -0,0 +1,98 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+)
+
+// Report is the result of validating a field.
+type Report struct {
+	// Solution is a shortest solution from the start. Solution is nil if the field is unsolvable.
+	Solution *Solution
+
+	// UnusedSwitches are the switches the player can reach the goal without toggling.
+	UnusedSwitches []int
+
+	// UnreachableRooms are the positions of the rooms the player can never enter.
+	UnreachableRooms [][3]int
+
+	// TooShort reports whether the solution is shorter than the minimum length given to ValidateField.
+	TooShort bool
+}
+
+// OK reports whether the field has no problems.
+func (r *Report) OK() bool {
+	return r.Solution != nil && len(r.UnusedSwitches) == 0 && len(r.UnreachableRooms) == 0 && !r.TooShort
+}
+
+// Problems returns the descriptions of the problems.
+func (r *Report) Problems() []string {
+	var ps []string
+	if r.Solution == nil {
+		ps = append(ps, "unsolvable")
+	}
+	for _, sw := range r.UnusedSwitches {
+		ps = append(ps, fmt.Sprintf("switch %c is not needed", 'A'+sw))
+	}
+	for _, p := range r.UnreachableRooms {
+		ps = append(ps, fmt.Sprintf("room (%d, %d, %d) is unreachable", p[0], p[1], p[2]))
+	}
+	if r.TooShort {
+		ps = append(ps, fmt.Sprintf("the solution is too short: %d moves", r.Solution.Len()))
+	}
+	return ps
+}
+
+// ValidateField checks the field at tile level by searching all the states reachable from the start.
+// A solution shorter than minSolutionLength moves is reported as too short.
+// ValidateField returns ErrTooLarge if the field is too large to search.
+func ValidateField(f *Field, minSolutionLength int) (*Report, error) {
+	sv, err := newSolver(f, maxSolverStates)
+	if err != nil {
+		return nil, err
+	}
+	start := NewState(f)
+	r := &Report{}
+	goal := sv.search(start, true)
+	if goal != -1 {
+		r.Solution = sv.solution(goal)
+		r.TooShort = r.Solution.Len() < minSolutionLength
+	}
+
+	if f.HasRooms() {
+		w, h, _ := f.TileSize()
+		for _, room := range f.rooms {
+			if room == nil {
+				continue
+			}
+			x, y := f.RoomCenter(room.x, room.y)
+			if !sv.reached(sv.indices[x+y*w+room.z*w*h]) {
+				r.UnreachableRooms = append(r.UnreachableRooms, [3]int{room.x, room.y, room.z})
+			}
+		}
+	}
+
+	if goal != -1 {
+		for i := 0; i < f.switches; i++ {
+			sv.disabledSwitches = 1 << uint(i)
+			if sv.search(start, false) != -1 {
+				r.UnusedSwitches = append(r.UnusedSwitches, i)
+			}
+		}
+		sv.disabledSwitches = 0
+	}
+	return r, nil
+}
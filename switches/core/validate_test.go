@@ -0,0 +1,147 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func TestValidateLevels(t *testing.T) {
+	testCases := []struct {
+		name              string
+		level             string
+		minSolutionLength int
+		problems          []string
+	}{
+		{
+			name:  "ok",
+			level: "@@[]A+GL\n  *A\n",
+		},
+		{
+			name:              "long enough",
+			level:             "@@[]A+GL\n  *A\n",
+			minSolutionLength: 5,
+		},
+		{
+			name:              "too short",
+			level:             "@@[]A+GL\n  *A\n",
+			minSolutionLength: 6,
+			problems:          []string{"the solution is too short: 5 moves"},
+		},
+		{
+			name:     "unused switch",
+			level:    "@@[]GL\n*A\n",
+			problems: []string{"switch A is not needed"},
+		},
+		{
+			name:     "one of the switches is unused",
+			level:    "@@[]A+GL\n  *A*B\n",
+			problems: []string{"switch B is not needed"},
+		},
+		{
+			name:     "unsolvable",
+			level:    "@@A+GL\n",
+			problems: []string{"unsolvable"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := mustParseLevel(t, tc.level)
+			r, err := core.ValidateField(f, tc.minSolutionLength)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := r.OK(), len(tc.problems) == 0; got != want {
+				t.Errorf("OK(): got %v, want %v", got, want)
+			}
+			if got := r.Problems(); !reflect.DeepEqual(got, tc.problems) {
+				t.Errorf("Problems(): got %q, want %q", got, tc.problems)
+			}
+			if r.Solution != nil {
+				if s := replay(t, f, r.Solution.Moves); !s.IsGoal(f) {
+					t.Errorf("the solution doesn't reach the goal")
+				}
+			}
+		})
+	}
+}
+
+func TestValidateUnreachableRoom(t *testing.T) {
+	// The room at (1, 0, 0) has no passages.
+	const data = `{
+  "version": 3,
+  "width": 2,
+  "height": 1,
+  "depth": 2,
+  "rooms": [
+    {"x": 0, "y": 0, "z": 0, "passages": {"downstairs": ""}},
+    {"x": 1, "y": 0, "z": 0},
+    {"x": 0, "y": 0, "z": 1, "passages": {"right": ""}},
+    {"x": 1, "y": 0, "z": 1, "passages": {"down": ""}},
+    {"x": 1, "y": 1, "z": 1, "goal": true}
+  ]
+}`
+	var f core.Field
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+	r, err := core.ValidateField(&f, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.UnreachableRooms, [][3]int{{1, 0, 0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("UnreachableRooms: got %v, want %v", got, want)
+	}
+	if got, want := r.Problems(), []string{"room (1, 0, 0) is unreachable"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Problems(): got %q, want %q", got, want)
+	}
+}
+
+func TestValidateGeneratedFields(t *testing.T) {
+	config := core.DefaultGeneratorConfig(3)
+	config.RejectInvalid = true
+	config.MinSolutionLength = 20
+	for seed := uint64(0); seed < 8; seed++ {
+		f, err := core.NewField(3, 3, 2, 3, seed, config)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		r, err := core.ValidateField(f, config.MinSolutionLength)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if !r.OK() {
+			t.Errorf("seed %d: %v", seed, r.Problems())
+		}
+	}
+}
+
+func TestValidateTooLarge(t *testing.T) {
+	var level strings.Builder
+	level.WriteString("@@")
+	for c := 'A'; c <= 'Z'; c++ {
+		level.WriteString("*" + string(c))
+	}
+	level.WriteString("GL\n")
+	f := mustParseLevel(t, level.String())
+	if _, err := core.ValidateField(f, 0); err != core.ErrTooLarge {
+		t.Errorf("ValidateField: got %v, want %v", err, core.ErrTooLarge)
+	}
+}
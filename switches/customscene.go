@@ -0,0 +1,261 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/font"
+)
+
+// customSettings is the parameters of a field the player chooses on the custom scene.
+type customSettings struct {
+	width    int
+	height   int
+	depth    int
+	switches int
+	// oneWay and deadEnds are in percent.
+	oneWay   int
+	deadEnds int
+	// seed is the digits the player typed. A random seed is used if seed is empty.
+	seed string
+}
+
+func defaultCustomSettings() *customSettings {
+	return &customSettings{
+		width:    4,
+		height:   4,
+		depth:    4,
+		switches: 4,
+		oneWay:   10,
+	}
+}
+
+func (c *customSettings) difficulty() *core.Difficulty {
+	config := core.DefaultGeneratorConfig(c.switches)
+	config.OneWayRatio = float64(c.oneWay) / 100
+	config.DeadEndDensity = float64(c.deadEnds) / 100
+	return &core.Difficulty{
+		Name:     "CUSTOM",
+		Width:    c.width,
+		Height:   c.height,
+		Depth:    c.depth,
+		Switches: c.switches,
+		Config:   config,
+	}
+}
+
+// valid reports whether a field can be generated with the settings.
+func (c *customSettings) valid() bool {
+	d := c.difficulty()
+	return d.Config.Validate(d.Width, d.Height, d.Depth, d.Switches) == nil
+}
+
+type customParam struct {
+	name  string
+	value *int
+	min   int
+	max   int
+	step  int
+	unit  string
+	y     int
+}
+
+const (
+	customParamDecX   = 160
+	customParamValueX = 176
+	customParamIncX   = 216
+	customSeedX       = 88
+)
+
+type customScene struct {
+	game   *Game
+	params []*customParam
+	seedY  int
+	start  *mode
+	back   *mode
+	// The text under the cursor is either a mode or an arrow to change a param by selectedDelta.
+	selectedMode  *mode
+	selectedParam *customParam
+	selectedDelta int
+}
+
+func newCustomScene(game *Game) *customScene {
+	if game.customSettings == nil {
+		game.customSettings = defaultCustomSettings()
+	}
+	c := game.customSettings
+	s := &customScene{
+		game: game,
+		params: []*customParam{
+			{name: "WIDTH", value: &c.width, min: 1, max: 16, step: 1},
+			{name: "HEIGHT", value: &c.height, min: 1, max: 16, step: 1},
+			{name: "DEPTH", value: &c.depth, min: 1, max: 16, step: 1},
+			{name: "SWITCHES", value: &c.switches, min: 0, max: 16, step: 1},
+			{name: "ONE-WAY", value: &c.oneWay, min: 0, max: 50, step: 5, unit: "%"},
+			{name: "DEAD ENDS", value: &c.deadEnds, min: 0, max: 300, step: 25, unit: "%"},
+		},
+	}
+	y := 96
+	for _, p := range s.params {
+		p.y = y
+		y += 16
+	}
+	s.seedY = y
+	y += 16
+	s.start = &mode{text: "START", kind: modeKindNewGame, x: 48, y: y + 8}
+	s.back = &mode{text: "BACK", x: 48, y: y + 24}
+	return s
+}
+
+func (s *customScene) Update() error {
+	if s.game.input.IsKeyTriggered(ebiten.KeyEscape) {
+		s.game.goTo(newTitleScene(s.game))
+		return nil
+	}
+	s.selectedMode = nil
+	s.selectedParam = nil
+	s.selectedDelta = 0
+	x, y := ebiten.CursorPosition()
+	for _, m := range []*mode{s.start, s.back} {
+		if m == s.start && !s.game.customSettings.valid() {
+			continue
+		}
+		w, h := m.size()
+		if m.x <= x && x < m.x+w && m.y <= y && y < m.y+h {
+			s.selectedMode = m
+		}
+	}
+	for _, p := range s.params {
+		if y < p.y || p.y+font.ArcadeFont.TextHeight("<") <= y {
+			continue
+		}
+		w := font.ArcadeFont.TextWidth("<")
+		switch {
+		case customParamDecX <= x && x < customParamDecX+w:
+			s.selectedParam = p
+			s.selectedDelta = -p.step
+		case customParamIncX <= x && x < customParamIncX+w:
+			s.selectedParam = p
+			s.selectedDelta = p.step
+		}
+	}
+	s.updateSeed()
+	if !s.game.input.IsTriggered() {
+		return nil
+	}
+	switch {
+	case s.selectedParam != nil:
+		p := s.selectedParam
+		v := *p.value + s.selectedDelta
+		if p.min <= v && v <= p.max {
+			*p.value = v
+		}
+	case s.selectedMode == s.start:
+		c := s.game.customSettings
+		m := &mode{kind: modeKindNewGame, difficulty: c.difficulty()}
+		if c.seed != "" {
+			// updateSeed accepts only the digits of a uint64.
+			seed, err := strconv.ParseUint(c.seed, 10, 64)
+			if err != nil {
+				panic(fmt.Sprintf("switches: invalid seed: %v", err))
+			}
+			m.seed = seed
+			m.fixedSeed = true
+		}
+		t := newTitleScene(s.game)
+		t.startLoading(m)
+		s.game.goTo(t)
+	case s.selectedMode == s.back:
+		s.game.goTo(newTitleScene(s.game))
+	}
+	return nil
+}
+
+// updateSeed edits the seed with the typed digits and Backspace.
+func (s *customScene) updateSeed() {
+	c := s.game.customSettings
+	if s.game.input.IsKeyTriggered(ebiten.KeyBackspace) && c.seed != "" {
+		c.seed = c.seed[:len(c.seed)-1]
+	}
+	for _, r := range ebiten.AppendInputChars(nil) {
+		if r < '0' || '9' < r {
+			continue
+		}
+		// Drop leading zeros so that the seed can't grow without limit.
+		seed := strings.TrimLeft(c.seed+string(r), "0")
+		if seed == "" {
+			seed = "0"
+		}
+		if _, err := strconv.ParseUint(seed, 10, 64); err != nil {
+			continue
+		}
+		c.seed = seed
+	}
+}
+
+func (s *customScene) Draw(screen *ebiten.Image) {
+	screen.Fill(backgroundColor)
+	title := "CUSTOM"
+	w := font.ArcadeFont.TextWidth(title)
+	font.ArcadeFont.DrawText(screen, title, (screenWidth-w*2)/2, 64, 2, color.White)
+	highlight := color.RGBA{0xff, 0xee, 0x58, 0xff}
+	disabled := color.RGBA{0x75, 0x75, 0x75, 0xff}
+	valid := s.game.customSettings.valid()
+	for _, p := range s.params {
+		font.ArcadeFont.DrawText(screen, p.name, 48, p.y, 1, color.White)
+		for _, a := range []struct {
+			text  string
+			x     int
+			delta int
+		}{
+			{"<", customParamDecX, -p.step},
+			{">", customParamIncX, p.step},
+		} {
+			clr := color.Color(color.White)
+			if s.selectedParam == p && s.selectedDelta == a.delta {
+				clr = highlight
+			}
+			font.ArcadeFont.DrawText(screen, a.text, a.x, p.y, 1, clr)
+		}
+		font.ArcadeFont.DrawText(screen, fmt.Sprintf("%d%s", *p.value, p.unit), customParamValueX, p.y, 1, color.White)
+	}
+	seed := s.game.customSettings.seed
+	if seed == "" {
+		seed = "RANDOM"
+	}
+	font.ArcadeFont.DrawText(screen, "SEED", 48, s.seedY, 1, color.White)
+	font.ArcadeFont.DrawText(screen, seed, customSeedX, s.seedY, 1, color.White)
+	for _, m := range []*mode{s.start, s.back} {
+		clr := color.Color(color.White)
+		if s.selectedMode == m {
+			clr = highlight
+		}
+		if m == s.start && !valid {
+			clr = disabled
+		}
+		font.ArcadeFont.DrawText(screen, m.text, m.x, m.y, 1, clr)
+	}
+	if !valid {
+		font.ArcadeFont.DrawText(screen, "TOO MANY SWITCHES", s.start.x+56, s.start.y, 1, color.White)
+	}
+	font.ArcadeFont.DrawText(screen, "TYPE DIGITS TO SET THE SEED", 8, screenHeight-12, 1, color.White)
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"sort"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+type roomPos struct {
+	x, y, z int
+}
+
+type passagePos struct {
+	room roomPos
+	dir  core.Dir
+}
+
+// exploration remembers the rooms the player has entered and the passages the player has seen in them.
+// With the fog of war, only these rooms and passages are shown.
+type exploration struct {
+	fog      bool
+	rooms    map[roomPos]struct{}
+	passages map[passagePos]struct{}
+}
+
+func newExploration(fog bool) *exploration {
+	return &exploration{
+		fog:      fog,
+		rooms:    map[roomPos]struct{}{},
+		passages: map[passagePos]struct{}{},
+	}
+}
+
+// visit marks the room at the tile position (x, y, z) and its passages as discovered.
+func (e *exploration) visit(f *core.Field, x, y, z int) {
+	rx, ry := f.RoomPosition(x, y)
+	r, ok := f.Room(rx, ry, z)
+	if !ok {
+		return
+	}
+	p := roomPos{rx, ry, z}
+	if _, ok := e.rooms[p]; ok {
+		return
+	}
+	e.rooms[p] = struct{}{}
+	for d, ok := range r.Passages {
+		if ok {
+			e.passages[passagePos{p, core.Dir(d)}] = struct{}{}
+		}
+	}
+}
+
+// roomKnown reports whether the room at the room position (x, y, z) is shown.
+func (e *exploration) roomKnown(x, y, z int) bool {
+	if !e.fog {
+		return true
+	}
+	_, ok := e.rooms[roomPos{x, y, z}]
+	return ok
+}
+
+// passageKnown reports whether the passage in the direction d from the room at (x, y, z) is shown.
+func (e *exploration) passageKnown(x, y, z int, d core.Dir) bool {
+	if !e.fog {
+		return true
+	}
+	_, ok := e.passages[passagePos{roomPos{x, y, z}, d}]
+	return ok
+}
+
+func (e *exploration) roomList() [][3]int {
+	var rs [][3]int
+	for p := range e.rooms {
+		rs = append(rs, [3]int{p.x, p.y, p.z})
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		return lessInts(rs[i][:], rs[j][:])
+	})
+	return rs
+}
+
+func (e *exploration) passageList() [][4]int {
+	var ps [][4]int
+	for p := range e.passages {
+		ps = append(ps, [4]int{p.room.x, p.room.y, p.room.z, int(p.dir)})
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return lessInts(ps[i][:], ps[j][:])
+	})
+	return ps
+}
+
+func lessInts(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
@@ -17,9 +17,14 @@ package switches
 import (
 	"errors"
 	"image/color"
+	"io"
+	"log"
 
 	"github.com/hajimehoshi/ebiten/v2"
 
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/assets"
+	"github.com/hajimehoshi/switches/switches/internal/font"
 	"github.com/hajimehoshi/switches/switches/internal/input"
 )
 
@@ -39,19 +44,50 @@ var (
 )
 
 type Game struct {
-	scene scene
-	tasks []task
-	input *input.Input
+	scene   scene
+	tasks   []task
+	input   *input.Input
+	tileset *tileset
+
+	// customSettings is the last settings on the custom scene.
+	customSettings *customSettings
+
+	// fog is true when new games hide the rooms the player has not entered.
+	fog bool
+}
+
+// SetAssetDir sets the directory that has image files to use instead of the embedded ones.
+// SetAssetDir must be called before NewGame.
+func SetAssetDir(dir string) {
+	assets.SetOverrideDir(dir)
 }
 
 func NewGame() (*Game, error) {
+	if err := font.Load(); err != nil {
+		return nil, err
+	}
+	tileset, err := loadTileset(defaultTileset)
+	if err != nil {
+		return nil, err
+	}
 	g := &Game{
-		input: input.New(),
+		input:   input.New(),
+		tileset: tileset,
 	}
 	g.scene = newTitleScene(g)
 	return g, nil
 }
 
+// LoadLevel parses a text level and starts playing it instead of showing the title.
+func (g *Game) LoadLevel(r io.Reader) error {
+	f, err := core.ParseLevel(r)
+	if err != nil {
+		return err
+	}
+	g.goTo(newGameScene(f, nil, g))
+	return nil
+}
+
 const (
 	screenWidth  = 256
 	screenHeight = 256
@@ -81,6 +117,7 @@ func (g *Game) goTo(scene scene) {
 func (g *Game) Run() error {
 	ebiten.SetWindowTitle("Switches")
 	ebiten.SetWindowSize(screenWidth*2, screenHeight*2)
+	ebiten.SetWindowClosingHandled(true)
 	if err := ebiten.RunGame(g); err != nil {
 		panic(err)
 	}
@@ -88,6 +125,9 @@ func (g *Game) Run() error {
 }
 
 func (g *Game) Update() error {
+	if ebiten.IsWindowBeingClosed() {
+		return g.terminate()
+	}
 	g.input.Update()
 	if consumed, err := g.consumeTask(); err != nil {
 		return err
@@ -99,6 +139,21 @@ func (g *Game) Update() error {
 	return nil
 }
 
+func (g *Game) terminate() error {
+	// Finish the running tasks so that the saved state is consistent.
+	for 0 < len(g.tasks) {
+		if _, err := g.consumeTask(); err != nil {
+			return err
+		}
+	}
+	if s, ok := g.scene.(*gameScene); ok && !s.state.IsGoal(s.field) {
+		if err := s.save(); err != nil {
+			log.Printf("switches: failed to save the game: %v", err)
+		}
+	}
+	return ebiten.Termination
+}
+
 func (g *Game) Draw(screen *ebiten.Image) {
 	g.scene.Draw(screen)
 }
@@ -106,3 +161,17 @@ func (g *Game) Draw(screen *ebiten.Image) {
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
 	return screenWidth, screenHeight
 }
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a < b {
+		return b
+	}
+	return a
+}
@@ -15,161 +15,311 @@
 package switches
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"image/color"
+	"log"
+	"math/rand/v2"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 
+	"github.com/hajimehoshi/switches/switches/core"
 	"github.com/hajimehoshi/switches/switches/internal/font"
 )
 
-type player struct {
-	x         int
-	y         int
-	z         int
-	dir       dir
-	moveCount int
-}
-
 type gameScene struct {
 	game          *Game
-	field         *field
-	player        *player
-	tilesImage    *ebiten.Image
-	switchStates  []bool
+	field         *core.Field
+	state         *core.State
+	moveCount     int
 	selectedTileX int
 	selectedTileY int
 	goal          bool
+
+	history core.History
+	undos   int
+
+	// ownsSave is true if the save data is of this game, i.e. the game was continued from it or has written it.
+	ownsSave bool
+
+	// hint is cleared when the player moves.
+	hint   *hint
+	hints  int
+	hinter *hinter
+
+	// minimap is not nil while the minimap is shown.
+	minimap *minimap
+
+	exploration *exploration
+
+	// par is a shortest solution of the field. par is nil if the field can't be solved.
+	par *core.Solution
+
+	// rating is nil if the field can't be rated.
+	rating *core.Rating
+
+	startTime time.Time
+	goalTime  time.Time
+
+	// difficulty is the mode to generate the next field. difficulty is nil if the field is not generated.
+	difficulty *core.Difficulty
+
+	// seed is the seed of the field. seed is meaningful only if difficulty is not nil.
+	seed uint64
+
+	// nextCh is not nil while the next game is being generated in background.
+	nextCh     chan loadingResult
+	cancelNext context.CancelFunc
+	next       *loadingResult
+	// waitingNext is true when the player chose NEXT before the next game is ready.
+	waitingNext bool
+
+	goalItems    []*mode
+	selectedItem *mode
 }
 
-func newGameScene(width, height, depth, switches int, game *Game) (*gameScene, error) {
-	f, err := newField(width, height, depth, switches)
-	if err != nil {
-		return nil, err
+// newGameScene returns a game scene for the field f.
+// If the field can't be rated, for example when a loaded field is unsolvable, the field is played unrated.
+func newGameScene(f *core.Field, difficulty *core.Difficulty, game *Game) *gameScene {
+	state := core.NewState(f)
+	rating, err := core.RateField(f)
+	if err != nil && err != core.ErrTooLarge {
+		log.Printf("switches: failed to rate the field: %v", err)
 	}
-	tilesImage, _, err := ebitenutil.NewImageFromFile("tiles.png")
-	if err != nil {
-		return nil, err
+	var par *core.Solution
+	if rating != nil {
+		par = rating.Solution
 	}
-	px, py := f.start()
 	s := &gameScene{
-		game:         game,
-		field:        f,
-		player:       &player{x: px, y: py, z: 0},
-		tilesImage:   tilesImage,
-		switchStates: make([]bool, switches),
+		game:        game,
+		field:       f,
+		state:       state,
+		par:         par,
+		rating:      rating,
+		startTime:   time.Now(),
+		difficulty:  difficulty,
+		seed:        f.Seed(),
+		exploration: newExploration(game.fog && f.HasRooms()),
+		hinter:      newHinter(f, par),
+	}
+	s.exploration.visit(f, state.X, state.Y, state.Z)
+	x := 72
+	if difficulty != nil {
+		s.goalItems = append(s.goalItems, &mode{text: "NEXT", x: x, y: 216})
+		// Leave space for "LOADING...".
+		x += 96
+	}
+	s.goalItems = append(s.goalItems, &mode{text: "TITLE", x: x, y: 216})
+	return s
+}
+
+// startNextGame starts generating the next field of the same difficulty in background.
+func (s *gameScene) startNextGame() {
+	d := *s.difficulty
+	if d.Config != nil {
+		c := *d.Config
+		d.Config = &c
+	} else {
+		d.Config = core.DefaultGeneratorConfig(d.Switches)
+	}
+	// Use only one goroutine not to slow down the current game. This doesn't change the generated field.
+	d.Config.Parallelism = 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan loadingResult, 1)
+	seed := rand.Uint64()
+	go func() {
+		f, err := d.NewFieldContext(ctx, seed, nil)
+		if err != nil {
+			ch <- loadingResult{nil, err}
+			return
+		}
+		ch <- loadingResult{newGameScene(f, s.difficulty, s.game), nil}
+	}()
+	s.nextCh = ch
+	s.cancelNext = cancel
+}
+
+func (s *gameScene) updateGoal() error {
+	if s.nextCh != nil {
+		select {
+		case r := <-s.nextCh:
+			s.next = &r
+			s.nextCh = nil
+			if r.err != nil {
+				log.Printf("switches: failed to generate the next field: %v", r.err)
+				// Hide NEXT.
+				s.goalItems = s.goalItems[1:]
+				s.goalItems[0].x = 72
+				s.waitingNext = false
+			}
+		default:
+		}
+	}
+	if s.waitingNext {
+		if s.next == nil {
+			return nil
+		}
+		s.next.gameScene.startTime = time.Now()
+		s.game.goTo(s.next.gameScene)
+		return nil
+	}
+
+	s.selectedItem = nil
+	x, y := ebiten.CursorPosition()
+	for _, i := range s.goalItems {
+		w, h := i.size()
+		if i.x <= x && x < i.x+w && i.y <= y && y < i.y+h {
+			s.selectedItem = i
+			break
+		}
+	}
+	if !s.game.input.IsTriggered() || s.selectedItem == nil {
+		return nil
+	}
+	if s.selectedItem.text == "NEXT" {
+		s.waitingNext = true
+		return nil
+	}
+	if s.cancelNext != nil {
+		s.cancelNext()
+	}
+	s.game.goTo(newTitleScene(s.game))
+	return nil
+}
+
+// reachGoal finishes the game. The save data is removed only if it is of this game, so that finishing another game
+// doesn't remove the game in progress.
+func (s *gameScene) reachGoal() {
+	s.goal = true
+	s.goalTime = time.Now()
+	if !s.ownsSave {
+		return
+	}
+	if err := removeSaveFile(); err != nil {
+		log.Printf("switches: failed to remove the save data: %v", err)
 	}
-	return s, nil
 }
 
 func (s *gameScene) Update() error {
-	tile, _ := s.field.tile(s.player.x, s.player.y, s.player.z, s.switchStates)
-	if tile == tileGoal {
-		s.goal = true
-		if s.game.input.IsTriggered() {
-			s.game.goTo(newTitleScene(s.game))
+	if s.state.IsGoal(s.field) {
+		if !s.goal {
+			s.reachGoal()
 		}
+		return s.updateGoal()
+	}
+	if s.difficulty != nil && s.nextCh == nil && s.next == nil {
+		s.startNextGame()
+	}
+	s.updateHint()
+	if s.minimap != nil {
+		if !s.minimap.update() {
+			s.minimap = nil
+		}
+		return nil
+	}
+	if s.game.input.IsKeyTriggered(ebiten.KeyM) && s.field.HasRooms() {
+		s.minimap = newMinimap(s)
 		return nil
 	}
 	s.updateSelectedTile()
+	// Game doesn't update the scene while a task is running, so undo and redo never happen during a move.
+	if s.game.input.IsKeyTriggered(ebiten.KeyZ) {
+		if state, ok := s.history.Undo(s.state); ok {
+			s.state = state
+			s.undos++
+			s.hint = nil
+		}
+		return nil
+	}
+	if s.game.input.IsKeyTriggered(ebiten.KeyY) {
+		if state, ok := s.history.Redo(s.state); ok {
+			s.state = state
+			s.hint = nil
+		}
+		return nil
+	}
+	// H shows the next step, and Shift+H also shows the route on the current floor.
+	if s.game.input.IsKeyTriggered(ebiten.KeyH) {
+		s.requestHint(ebiten.IsKeyPressed(ebiten.KeyShift))
+		return nil
+	}
 	if s.game.input.IsTriggered() {
-		w, h, _ := s.field.tileSize()
+		w, h, _ := s.field.TileSize()
 		if s.selectedTileX < 0 || w <= s.selectedTileX || s.selectedTileY < 0 || h <= s.selectedTileY {
 			return nil
 		}
-		tile, _ := s.field.tile(s.selectedTileX, s.selectedTileY, s.player.z, s.switchStates)
-		if !tile.isPassable() {
-			return nil
-		}
-		passable := func(x, y int) bool {
-			x0, y0, x1, y1 := s.tileRangeInScreen()
-			w, h, _ := s.field.tileSize()
-			if x < x0 || x1 <= x || y < y0 || y1 <= y {
-				return false
-			}
-			if x < 0 || w <= x || y < 0 || h <= y {
-				return false
-			}
-			t, _ := s.field.tile(x, y, s.player.z, s.switchStates)
-			// Don't go through switches.
-			if t == tileSwitch0 || t == tileSwitch1 {
-				return x == s.selectedTileX && y == s.selectedTileY
-			}
-			return t.isPassable()
-		}
-		path := calcPath(passable, s.player.x, s.player.y, s.selectedTileX, s.selectedTileY)
-		if len(path) == 0 {
+		tile, _ := s.field.Tile(s.selectedTileX, s.selectedTileY, s.state.Z, s.state.SwitchStates)
+		if !tile.IsPassable() {
 			return nil
 		}
-		i := 0
-		x, y := s.player.x, s.player.y
-		var moveTask task
-		s.game.appendTask(func() error {
-			if len(path) <= i {
-				return taskTerminated
-			}
-			if moveTask == nil {
-				d := path[i]
-				switch d {
-				case dirLeft:
-					x--
-				case dirRight:
-					x++
-				case dirUp:
-					y--
-				case dirDown:
-					y++
-				}
-				moveTask = s.moveTask(d, x, y)
-			}
-			if err := moveTask(); err == nil {
-				return nil
-			} else if err != taskTerminated {
-				return err
-			}
-			moveTask = nil
-			i++
-			switch t, _ := s.field.tile(x, y, s.player.z, s.switchStates); t {
-			case tileSwitch0:
-				fallthrough
-			case tileSwitch1:
-				return taskTerminated
-			}
-			return nil
-		})
+		s.travel(s.selectedTileX, s.selectedTileY, s.state.Z)
 		return nil
 	}
 	// Move the player
-	nx, ny := s.player.x, s.player.y
-	w, h, _ := s.field.tileSize()
-	var dir dir
-	if !tile.oneWay() {
-		if ebiten.IsKeyPressed(ebiten.KeyLeft) || tile == tileOneWayLeft {
-			nx = max(s.player.x-1, 0)
-			dir = dirLeft
-		} else if ebiten.IsKeyPressed(ebiten.KeyRight) || tile == tileOneWayRight {
-			nx = min(s.player.x+1, w-1)
-			dir = dirRight
-		} else if ebiten.IsKeyPressed(ebiten.KeyUp) || tile == tileOneWayUp {
-			ny = max(s.player.y-1, 0)
-			dir = dirUp
-		} else if ebiten.IsKeyPressed(ebiten.KeyDown) || tile == tileOneWayDown {
-			ny = min(s.player.y+1, h-1)
-			dir = dirDown
-		}
-	}
-	if s.player.x == nx && s.player.y == ny {
+	var dir core.Dir
+	switch {
+	case ebiten.IsKeyPressed(ebiten.KeyLeft):
+		dir = core.DirLeft
+	case ebiten.IsKeyPressed(ebiten.KeyRight):
+		dir = core.DirRight
+	case ebiten.IsKeyPressed(ebiten.KeyUp):
+		dir = core.DirUp
+	case ebiten.IsKeyPressed(ebiten.KeyDown):
+		dir = core.DirDown
+	default:
 		return nil
 	}
-	if t, _ := s.field.tile(nx, ny, s.player.z, s.switchStates); !t.isPassable() {
+	if _, _, ok := s.state.Next(s.field, dir); !ok {
 		return nil
 	}
-	s.game.appendTask(s.moveTask(dir, nx, ny))
+	s.history.Push(s.state)
+	s.game.appendTask(s.moveTask(dir))
 	return nil
 }
 
+// travel starts moving the player to the tile (x, y, z) along a shortest path, and reports whether there is a path.
+// The path might go through other floors, but not through the rooms the player hasn't discovered.
+func (s *gameScene) travel(x, y, z int) bool {
+	passable := func(x, y, z int) bool {
+		rx, ry := s.field.RoomPosition(x, y)
+		return s.exploration.roomKnown(rx, ry, z)
+	}
+	path := core.CalcPath3D(s.field, s.state, passable, x, y, z)
+	if len(path) == 0 {
+		return false
+	}
+	s.history.Push(s.state)
+	i := 0
+	var moveTask task
+	s.game.appendTask(func() error {
+		if len(path) <= i {
+			return taskTerminated
+		}
+		if moveTask == nil {
+			moveTask = s.moveTask(path[i])
+		}
+		if err := moveTask(); err == nil {
+			return nil
+		} else if err != taskTerminated {
+			return err
+		}
+		moveTask = nil
+		i++
+		switch t, _ := s.state.Tile(s.field); t {
+		case core.TileSwitch0:
+			fallthrough
+		case core.TileSwitch1:
+			return taskTerminated
+		}
+		return nil
+	})
+	return true
+}
+
 func (s *gameScene) updateSelectedTile() {
 	x, y := ebiten.CursorPosition()
 	ox, oy := s.tileOffset()
@@ -178,41 +328,31 @@ func (s *gameScene) updateSelectedTile() {
 	s.selectedTileY = y0 + (y-oy)/gridSize
 }
 
-func (s *gameScene) moveTask(dir dir, nextX, nextY int) task {
+func (s *gameScene) moveTask(dir core.Dir) task {
 	started := false
 	return func() error {
 		if !started {
-			s.player.dir = dir
-			s.player.moveCount = playerMaxMoveCount
+			s.state.Dir = dir
+			s.moveCount = playerMaxMoveCount
+			s.hint = nil
 			started = true
 		}
-		if 0 < s.player.moveCount {
-			s.player.moveCount--
+		if 0 < s.moveCount {
+			s.moveCount--
 		}
-		if 0 < s.player.moveCount {
+		if 0 < s.moveCount {
 			return nil
 		}
-		s.player.x = nextX
-		s.player.y = nextY
-		switch t, sw := s.field.tile(nextX, nextY, s.player.z, s.switchStates); t {
-		case tileUpstairs:
-			fallthrough
-		case tileOneWayUpstairs:
-			s.player.z -= 1
-		case tileDownstairs:
-			fallthrough
-		case tileOneWayDownstairs:
-			s.player.z += 1
-		case tileSwitch0:
-			fallthrough
-		case tileSwitch1:
+		sw, ok := s.state.Move(s.field, dir)
+		s.exploration.visit(s.field, s.state.X, s.state.Y, s.state.Z)
+		if ok {
 			wait := 10
 			s.game.appendTask(func() error {
 				if 0 < wait {
 					wait--
 					return nil
 				}
-				s.switchStates[sw] = !s.switchStates[sw]
+				s.state.Toggle(sw)
 				return taskTerminated
 			})
 		}
@@ -223,25 +363,25 @@ func (s *gameScene) moveTask(dir dir, nextX, nextY int) task {
 func (s *gameScene) tileRangeInScreen() (int, int, int, int) {
 	nx := screenWidth / gridSize
 	ny := screenHeight / gridSize
-	x0 := s.player.x - nx/2 - 1
-	y0 := s.player.y - ny/2 - 1
-	x1 := s.player.x + nx/2 + 1
-	y1 := s.player.y + ny/2 + 1
+	x0 := s.state.X - nx/2 - 1
+	y0 := s.state.Y - ny/2 - 1
+	x1 := s.state.X + nx/2 + 1
+	y1 := s.state.Y + ny/2 + 1
 	return x0, y0, x1, y1
 }
 
 func (s *gameScene) tileOffset() (int, int) {
 	ox, oy := -gridSize/2-gridSize, -gridSize/2-gridSize
-	if 0 < s.player.moveCount {
-		d := gridSize * (playerMaxMoveCount - s.player.moveCount) / playerMaxMoveCount
-		switch s.player.dir {
-		case dirLeft:
+	if 0 < s.moveCount {
+		d := gridSize * (playerMaxMoveCount - s.moveCount) / playerMaxMoveCount
+		switch s.state.Dir {
+		case core.DirLeft:
 			ox += d
-		case dirRight:
+		case core.DirRight:
 			ox -= d
-		case dirUp:
+		case core.DirUp:
 			oy += d
-		case dirDown:
+		case core.DirDown:
 			oy -= d
 		}
 	}
@@ -272,9 +412,11 @@ var (
 type tileParts struct {
 	scene   *gameScene
 	dst     []int
-	src     []int
+	src     []image.Rectangle
 	skips   map[int]struct{}
 	letters []*switchLetter
+	// dims are the indices of the tiles in the remembered rooms other than the current room.
+	dims []int
 }
 
 func newTileParts(scene *gameScene) *tileParts {
@@ -285,9 +427,9 @@ func newTileParts(scene *gameScene) *tileParts {
 	sw := x1 - x0 + 1
 	l := sw * (y1 - y0 + 1)
 	p.dst = make([]int, l*2)
-	p.src = make([]int, l*2)
+	p.src = make([]image.Rectangle, l)
 	p.skips = map[int]struct{}{}
-	player := p.scene.player
+	state := p.scene.state
 	for i := 0; i < l; i++ {
 		x := x0 + i/sw
 		y := y0 + i%sw
@@ -295,26 +437,36 @@ func newTileParts(scene *gameScene) *tileParts {
 			p.skips[i] = struct{}{}
 			continue
 		}
-		w, h, _ := p.scene.field.tileSize()
+		w, h, _ := p.scene.field.TileSize()
 		if w <= x || h <= y {
 			p.skips[i] = struct{}{}
 			continue
 		}
+		dim := false
+		if e := p.scene.exploration; e.fog {
+			rx, ry := p.scene.field.RoomPosition(x, y)
+			if !e.roomKnown(rx, ry, state.Z) {
+				p.skips[i] = struct{}{}
+				continue
+			}
+			px, py := p.scene.field.RoomPosition(state.X, state.Y)
+			dim = rx != px || ry != py
+		}
 		ox, oy := p.scene.tileOffset()
 		dx := (i/sw)*gridSize + ox
 		dy := (i%sw)*gridSize + oy
 		p.dst[2*i] = dx
 		p.dst[2*i+1] = dy
-		t, s := p.scene.field.tile(x, y, player.z, p.scene.switchStates)
+		t, s := p.scene.field.Tile(x, y, state.Z, state.SwitchStates)
 		switch t {
-		case tileNone:
+		case core.TileNone:
 			p.skips[i] = struct{}{}
 			continue
-		case tileSwitch0:
+		case core.TileSwitch0:
 			fallthrough
-		case tileSwitch1:
+		case core.TileSwitch1:
 			clr := switchLetterColor0
-			if p.scene.switchStates[s] {
+			if state.SwitchStates[s] {
 				clr = switchLetterColor1
 			}
 			p.letters = append(p.letters, &switchLetter{
@@ -323,12 +475,12 @@ func newTileParts(scene *gameScene) *tileParts {
 				x:      dx + 4,
 				y:      dy + 3,
 			})
-		case tileSwitchedTileValid:
+		case core.TileSwitchedTileValid:
 			fallthrough
-		case tileSwitchedTileInvalid:
+		case core.TileSwitchedTileInvalid:
 			clr := switchLetterColor2
-			if (p.scene.switchStates[s] && t == tileSwitchedTileValid) ||
-				(!p.scene.switchStates[s] && t == tileSwitchedTileInvalid) {
+			if (state.SwitchStates[s] && t == core.TileSwitchedTileValid) ||
+				(!state.SwitchStates[s] && t == core.TileSwitchedTileInvalid) {
 				clr = switchLetterColor3
 			}
 			p.letters = append(p.letters, &switchLetter{
@@ -338,41 +490,23 @@ func newTileParts(scene *gameScene) *tileParts {
 				y:      dy + 4,
 			})
 		}
-		type position struct {
-			X, Y int
+		p.src[i] = p.scene.game.tileset.tiles[t]
+		if dim {
+			p.dims = append(p.dims, i)
 		}
-		pos := map[tile]position{
-			tileNone:                {0, 0},
-			tileRegular:             {1 * gridSize, 0},
-			tileUpstairs:            {4 * gridSize, 0},
-			tileDownstairs:          {2 * gridSize, 0},
-			tileOneWayLeft:          {7 * gridSize, 0},
-			tileOneWayRight:         {9 * gridSize, 0},
-			tileOneWayUp:            {8 * gridSize, 0},
-			tileOneWayDown:          {6 * gridSize, 0},
-			tileOneWayUpstairs:      {5 * gridSize, 0},
-			tileOneWayDownstairs:    {3 * gridSize, 0},
-			tileSwitch0:             {10 * gridSize, 0},
-			tileSwitch1:             {11 * gridSize, 0},
-			tileSwitchedTileValid:   {1 * gridSize, 0},
-			tileSwitchedTileInvalid: {0, 0},
-			tileGoal:                {12 * gridSize, 0},
-		}[t]
-		p.src[2*i] = pos.X
-		p.src[2*i+1] = pos.Y
 	}
 	return p
 }
 
-func (p *tileParts) draw(screen *ebiten.Image, tilesImage *ebiten.Image) {
-	for i := 0; i < len(p.dst)/2; i++ {
+func (p *tileParts) draw(screen *ebiten.Image, tileset *tileset) {
+	for i := 0; i < len(p.src); i++ {
 		if _, ok := p.skips[i]; ok {
 			continue
 		}
-		op := &ebiten.DrawImageOptions{}
-		op.GeoM.Translate(float64(p.dst[2*i]), float64(p.dst[2*i+1]))
-		x, y := p.src[2*i], p.src[2*i+1]
-		screen.DrawImage(tilesImage.SubImage(image.Rect(x, y, x+gridSize, y+gridSize)).(*ebiten.Image), op)
+		tileset.draw(screen, p.src[i], p.dst[2*i], p.dst[2*i+1])
+	}
+	for _, i := range p.dims {
+		vector.DrawFilledRect(screen, float32(p.dst[2*i]), float32(p.dst[2*i+1]), gridSize, gridSize, color.RGBA{0, 0, 0, 0x80}, false)
 	}
 }
 
@@ -383,13 +517,20 @@ func (p *tileParts) switchLetters() []*switchLetter {
 func (s *gameScene) Draw(screen *ebiten.Image) {
 	screen.Fill(backgroundColor)
 	tileParts := newTileParts(s)
-	tileParts.draw(screen, s.tilesImage)
+	tileParts.draw(screen, s.game.tileset)
+	s.drawHint(screen)
 	s.drawCursor(screen)
 	for _, l := range tileParts.switchLetters() {
 		font.ArcadeFont.DrawText(screen, string(l.letter), l.x, l.y, 1, l.color)
 	}
 	s.drawPlayer(screen)
 	s.drawFloorNumber(screen)
+	// The minimap has its own footer at the same place as the seed.
+	if s.minimap != nil {
+		s.minimap.draw(screen)
+	} else if !s.goal {
+		s.drawSeed(screen, 8)
+	}
 	if s.goal {
 		s.drawGoalMessage(screen)
 	}
@@ -400,30 +541,34 @@ func (s *gameScene) drawCursor(screen *ebiten.Image) {
 	x0, y0, _, _ := s.tileRangeInScreen()
 	dstX := s.selectedTileX*gridSize - x0*gridSize + ox
 	dstY := s.selectedTileY*gridSize - y0*gridSize + oy
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(dstX), float64(dstY))
-	screen.DrawImage(s.tilesImage.SubImage(image.Rect(16, 16, 16+gridSize, 16+gridSize)).(*ebiten.Image), op)
+	s.game.tileset.draw(screen, s.game.tileset.cursor, dstX, dstY)
 }
 
 func (s *gameScene) drawPlayer(screen *ebiten.Image) {
 	dstX := (screenWidth - gridSize) / 2
 	dstY := (screenHeight - gridSize) / 2
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Translate(float64(dstX), float64(dstY))
-	screen.DrawImage(s.tilesImage.SubImage(image.Rect(0, 16, 0+gridSize, 16+gridSize)).(*ebiten.Image), op)
+	s.game.tileset.draw(screen, s.game.tileset.player[s.state.Dir], dstX, dstY)
 }
 
-func (s *gameScene) drawFloorNumber(screen *ebiten.Image) {
-	z := s.player.z
-	msg := ""
+func floorName(z int) string {
 	if z == 0 {
-		msg = "GROUND"
-	} else {
-		msg = fmt.Sprintf("B%dF", z)
+		return "GROUND"
 	}
+	return fmt.Sprintf("B%dF", z)
+}
+
+func (s *gameScene) drawFloorNumber(screen *ebiten.Image) {
 	x := 8
 	y := 8
-	font.ArcadeFont.DrawTextWithShadow(screen, msg, x, y, 1, color.White)
+	font.ArcadeFont.DrawTextWithShadow(screen, floorName(s.state.Z), x, y, 1, color.White)
+}
+
+// drawSeed draws the seed at the bottom of the screen so that the player can share the field.
+func (s *gameScene) drawSeed(screen *ebiten.Image, x int) {
+	if s.difficulty == nil {
+		return
+	}
+	font.ArcadeFont.DrawTextWithShadow(screen, fmt.Sprintf("SEED %d", s.seed), x, screenHeight-16, 1, color.White)
 }
 
 var emptyImage *ebiten.Image
@@ -439,4 +584,78 @@ func (s *gameScene) drawGoalMessage(screen *ebiten.Image) {
 	x := (screenWidth - w*2) / 2
 	y := 64
 	font.ArcadeFont.DrawTextWithShadow(screen, msg, x, y, 2, color.White)
+
+	par := "---"
+	if s.par != nil {
+		par = fmt.Sprint(s.par.Len())
+	}
+	elapsed := s.goalTime.Sub(s.startTime)
+	lines := []string{
+		fmt.Sprintf("MOVES %6d", s.state.Steps),
+		fmt.Sprintf("PAR   %6s", par),
+		fmt.Sprintf("FLIPS %6d", s.state.Flips),
+		fmt.Sprintf("UNDOS %6d", s.undos),
+		fmt.Sprintf("HINTS %6d", s.hints),
+		fmt.Sprintf("TIME  %3d:%02d", int(elapsed.Minutes()), int(elapsed.Seconds())%60),
+		fmt.Sprintf("RATING%6s", ratingString(s.rating)),
+	}
+	x = 72
+	y = 88
+	for _, l := range lines {
+		font.ArcadeFont.DrawTextWithShadow(screen, l, x, y, 1, color.White)
+		y += 12
+	}
+	y += 4
+	font.ArcadeFont.DrawTextWithShadow(screen, "RANK", x, y, 1, color.White)
+	stars := s.stars()
+	for i := 0; i < maxStars; i++ {
+		clr := color.Color(switchLetterColor0)
+		if i < stars {
+			clr = switchLetterColor2
+		}
+		font.ArcadeFont.DrawTextWithShadow(screen, "*", x+48+i*16, y-4, 2, clr)
+	}
+	// Undo restores the moves and the flips too, so MOVES and the rank don't include the undone moves.
+	if 0 < s.undos {
+		msg := "UNDONE MOVES ARE NOT COUNTED"
+		w := font.ArcadeFont.TextWidth(msg)
+		font.ArcadeFont.DrawTextWithShadow(screen, msg, (screenWidth-w)/2, y+20, 1, color.White)
+	}
+
+	for _, i := range s.goalItems {
+		text := i.text
+		if text == "NEXT" && s.waitingNext {
+			text = "LOADING..."
+		}
+		clr := color.Color(color.White)
+		if s.selectedItem == i {
+			clr = color.RGBA{0xff, 0xee, 0x58, 0xff}
+		}
+		font.ArcadeFont.DrawTextWithShadow(screen, text, i.x, i.y, 1, clr)
+	}
+	s.drawSeed(screen, 72)
+}
+
+const maxStars = 3
+
+// stars returns the rating of the play, from 1 to maxStars.
+func (s *gameScene) stars() int {
+	if s.par == nil {
+		return 1
+	}
+	par := s.par.Len()
+	switch steps := s.state.Steps; {
+	case steps <= par:
+		return 3
+	case steps <= par*3/2:
+		return 2
+	}
+	return 1
+}
+
+func ratingString(r *core.Rating) string {
+	if r == nil {
+		return "---"
+	}
+	return fmt.Sprint(int(r.Score))
 }
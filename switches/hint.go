@@ -0,0 +1,253 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/font"
+)
+
+var (
+	hintRouteColor  = color.NRGBA{0xff, 0xee, 0x58, 0x40}
+	hintNextColor   = color.NRGBA{0xff, 0xee, 0x58, 0xa0}
+	hintSwitchColor = color.NRGBA{0x4f, 0xc3, 0xf7, 0xa0}
+)
+
+// hintMaxStates is the maximum number of states searched for a hint. This is much smaller than the limit of rating a
+// field, as the search runs while the player is playing. This still covers the fields of the difficulties.
+const hintMaxStates = 1 << 22
+
+// hint is a part of a shortest route from the player's state to the goal on the current floor.
+type hint struct {
+	// unavailable is true if no route is found.
+	unavailable bool
+
+	// searching is true while the route is being searched.
+	searching bool
+
+	// next is the tile of the next step.
+	next image.Point
+
+	// switchTile is the next switch to step on, if hasSwitch is true.
+	switchTile image.Point
+	hasSwitch  bool
+
+	// route is the tiles of the route. route is empty unless the full route is requested.
+	route []image.Point
+}
+
+// hintRoute is a shortest route to the goal. states[i] is the state before moves[i].
+type hintRoute struct {
+	states []*core.State
+	moves  []core.Dir
+}
+
+func newHintRoute(f *core.Field, s *core.State, sol *core.Solution) *hintRoute {
+	r := &hintRoute{
+		moves: sol.Moves,
+	}
+	st := s.Clone()
+	for _, m := range sol.Moves {
+		r.states = append(r.states, st.Clone())
+		if !st.Step(f, m) {
+			panic("not reach")
+		}
+	}
+	return r
+}
+
+// find returns the index of the state on the route at the same position with the same switch states as s,
+// or -1 if there is no such state.
+func (r *hintRoute) find(s *core.State) int {
+	for i, st := range r.states {
+		if sameHintState(st, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+func sameHintState(s1, s2 *core.State) bool {
+	if s1.X != s2.X || s1.Y != s2.Y || s1.Z != s2.Z {
+		return false
+	}
+	for i := range s1.SwitchStates {
+		if s1.SwitchStates[i] != s2.SwitchStates[i] {
+			return false
+		}
+	}
+	return true
+}
+
+type hintResult struct {
+	state *core.State
+	sol   *core.Solution
+	err   error
+}
+
+// hinter finds the routes for hints in background.
+//
+// hinter keeps the last found route. As the rest of a shortest route is also a shortest route, the route gives
+// the hints for all the states on it without searching again.
+type hinter struct {
+	field *core.Field
+	route *hintRoute
+
+	// tooLarge is true if the field is too large to search for hints.
+	tooLarge bool
+
+	// ch is not nil while a route is being searched.
+	ch chan hintResult
+
+	// fullRoute is true if the requested hint shows the full route.
+	fullRoute bool
+}
+
+// newHinter returns a hinter for the field f. If par is not nil, par is the first route from the start.
+func newHinter(f *core.Field, par *core.Solution) *hinter {
+	h := &hinter{
+		field: f,
+	}
+	if par != nil {
+		h.route = newHintRoute(f, core.NewState(f), par)
+	}
+	return h
+}
+
+// requestHint shows the hint for the current state. If the route is not found yet, requestHint starts searching
+// it in background, and the hint is shown when the search finishes.
+func (s *gameScene) requestHint(fullRoute bool) {
+	h := s.hinter
+	if h.tooLarge {
+		s.hint = &hint{unavailable: true}
+		return
+	}
+	if h.route != nil {
+		if i := h.route.find(s.state); i != -1 {
+			s.hint = newHint(s.field, h.route, i, fullRoute)
+			s.hints++
+			return
+		}
+	}
+	s.hint = &hint{searching: true}
+	h.fullRoute = fullRoute
+	if h.ch != nil {
+		// updateHint requests the hint again when the current search finishes.
+		return
+	}
+	// The channel is buffered so that the goroutine never blocks even after the scene is left.
+	ch := make(chan hintResult, 1)
+	state := s.state.Clone()
+	f := s.field
+	go func() {
+		sol, err := core.SolveLimit(f, state, hintMaxStates)
+		ch <- hintResult{state, sol, err}
+	}()
+	h.ch = ch
+}
+
+// updateHint receives the result of the search for a hint, if any.
+func (s *gameScene) updateHint() {
+	h := s.hinter
+	if h.ch == nil {
+		return
+	}
+	var r hintResult
+	select {
+	case r = <-h.ch:
+	default:
+		return
+	}
+	h.ch = nil
+	switch {
+	case r.err == nil:
+		h.route = newHintRoute(s.field, r.state, r.sol)
+	case r.err == core.ErrTooLarge:
+		h.tooLarge = true
+	default:
+		if s.hint != nil && s.hint.searching && sameHintState(r.state, s.state) {
+			// The goal is unreachable from the current state.
+			s.hint = &hint{unavailable: true}
+			return
+		}
+	}
+	// The hint is cleared if the player moved during the search.
+	if s.hint != nil && s.hint.searching {
+		s.requestHint(h.fullRoute)
+	}
+}
+
+// newHint returns the hint for the i-th state of the route r.
+func newHint(f *core.Field, r *hintRoute, i int, fullRoute bool) *hint {
+	h := &hint{}
+	s := r.states[i]
+	st := s.Clone()
+	for j, m := range r.moves[i:] {
+		if !st.Step(f, m) {
+			panic("not reach")
+		}
+		p := image.Pt(st.X, st.Y)
+		if j == 0 {
+			h.next = p
+		}
+		// Stairs end the route on the current floor.
+		if st.Z != s.Z {
+			break
+		}
+		if t, _ := st.Tile(f); !h.hasSwitch && (t == core.TileSwitch0 || t == core.TileSwitch1) {
+			h.switchTile = p
+			h.hasSwitch = true
+		}
+		if fullRoute {
+			h.route = append(h.route, p)
+		}
+	}
+	return h
+}
+
+func (s *gameScene) drawHint(screen *ebiten.Image) {
+	if s.hint == nil {
+		return
+	}
+	if s.hint.unavailable || s.hint.searching {
+		msg := "NO HINT"
+		if s.hint.searching {
+			msg = "SEARCHING..."
+		}
+		w := font.ArcadeFont.TextWidth(msg)
+		font.ArcadeFont.DrawTextWithShadow(screen, msg, screenWidth-8-w, 8, 1, color.White)
+		return
+	}
+	ox, oy := s.tileOffset()
+	x0, y0, _, _ := s.tileRangeInScreen()
+	fill := func(p image.Point, clr color.Color) {
+		x := float32((p.X-x0)*gridSize + ox)
+		y := float32((p.Y-y0)*gridSize + oy)
+		vector.DrawFilledRect(screen, x, y, gridSize, gridSize, clr, false)
+	}
+	for _, p := range s.hint.route {
+		fill(p, hintRouteColor)
+	}
+	if s.hint.hasSwitch {
+		fill(s.hint.switchTile, hintSwitchColor)
+	}
+	fill(s.hint.next, hintNextColor)
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"image"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+func newTestHintScene(t *testing.T) *gameScene {
+	t.Helper()
+	f, err := core.ParseLevel(strings.NewReader("@@[]A+GL\n  *A\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newGameScene(f, nil, &Game{})
+}
+
+func TestHintOnRoute(t *testing.T) {
+	s := newTestHintScene(t)
+	// The route from the start is known without searching.
+	s.requestHint(false)
+	if s.hint.searching || s.hint.unavailable {
+		t.Fatalf("got %+v, want a hint", s.hint)
+	}
+	if got, want := s.hint.next, image.Pt(1, 0); got != want {
+		t.Errorf("next: got %v, want %v", got, want)
+	}
+	if s.hinter.ch != nil {
+		t.Errorf("a search must not start")
+	}
+	if got, want := s.hints, 1; got != want {
+		t.Errorf("hints: got %d, want %d", got, want)
+	}
+}
+
+func TestHintOffRoute(t *testing.T) {
+	s := newTestHintScene(t)
+	// Go back to the start with the switch on.
+	for _, d := range []core.Dir{core.DirRight, core.DirDown, core.DirUp, core.DirLeft} {
+		if !s.state.Step(s.field, d) {
+			t.Fatalf("move %v is not possible", d)
+		}
+	}
+	s.requestHint(true)
+	if !s.hint.searching {
+		t.Fatalf("got %+v, want a search", s.hint)
+	}
+	if got, want := s.hints, 0; got != want {
+		t.Errorf("hints: got %d, want %d", got, want)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for s.hint.searching {
+		if time.Now().After(deadline) {
+			t.Fatal("the search doesn't finish")
+		}
+		time.Sleep(time.Millisecond)
+		s.updateHint()
+	}
+	if got, want := s.hint.route, []image.Point{{1, 0}, {2, 0}, {3, 0}}; !equalPoints(got, want) {
+		t.Errorf("route: got %v, want %v", got, want)
+	}
+	if got, want := s.hints, 1; got != want {
+		t.Errorf("hints: got %d, want %d", got, want)
+	}
+	// The found route is reused.
+	s.requestHint(false)
+	if s.hint.searching || s.hinter.ch != nil {
+		t.Errorf("the found route must be reused")
+	}
+}
+
+func equalPoints(ps1, ps2 []image.Point) bool {
+	if len(ps1) != len(ps2) {
+		return false
+	}
+	for i := range ps1 {
+		if ps1[i] != ps2[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package assets provides the files the game uses. The files are embedded in the binary,
+// and files in the override directory take precedence over the embedded ones.
+package assets
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+//go:embed *.png tilesets
+var embedded embed.FS
+
+var (
+	overrideDir string
+	images      = map[string]*ebiten.Image{}
+	m           sync.Mutex
+)
+
+// SetOverrideDir sets the directory that has files to use instead of the embedded ones.
+func SetOverrideDir(dir string) {
+	m.Lock()
+	defer m.Unlock()
+	overrideDir = dir
+	images = map[string]*ebiten.Image{}
+}
+
+// ReadFile reads the asset file of the name.
+func ReadFile(name string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	return readFile(name)
+}
+
+func readFile(name string) ([]byte, error) {
+	if overrideDir != "" {
+		b, err := os.ReadFile(filepath.Join(overrideDir, filepath.FromSlash(name)))
+		if err == nil {
+			return b, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	b, err := embedded.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("assets: %w", err)
+	}
+	return b, nil
+}
+
+// Image returns the image of the asset file of the name.
+func Image(name string) (*ebiten.Image, error) {
+	m.Lock()
+	defer m.Unlock()
+	if img, ok := images[name]; ok {
+		return img, nil
+	}
+	b, err := readFile(name)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("assets: decoding %s failed: %w", name, err)
+	}
+	eimg := ebiten.NewImageFromImage(img)
+	images[name] = eimg
+	return eimg, nil
+}
+
+// ReadDir returns the sorted names of the files in the directory, both in the override directory and embedded.
+func ReadDir(dir string) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	names := map[string]struct{}{}
+	if overrideDir != "" {
+		ents, err := os.ReadDir(filepath.Join(overrideDir, filepath.FromSlash(dir)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		for _, e := range ents {
+			names[e.Name()] = struct{}{}
+		}
+	}
+	ents, err := fs.ReadDir(embedded, path.Clean(dir))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	for _, e := range ents {
+		names[e.Name()] = struct{}{}
+	}
+	var sorted []string
+	for n := range names {
+		sorted = append(sorted, n)
+	}
+	sort.Strings(sorted)
+	return sorted, nil
+}
@@ -20,7 +20,8 @@ import (
 	"strings"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+
+	"github.com/hajimehoshi/switches/switches/internal/assets"
 )
 
 var (
@@ -45,12 +46,14 @@ func (f *Font) TextHeight(str string) int {
 	return f.charHeight
 }
 
-func init() {
-	eimg, _, err := ebitenutil.NewImageFromFile("arcadefont.png")
+// Load loads the fonts. Load must be called before using the fonts.
+func Load() error {
+	eimg, err := assets.Image("arcadefont.png")
 	if err != nil {
-		panic(err)
+		return err
 	}
 	ArcadeFont = &Font{eimg, 32, 16, 8, 8}
+	return nil
 }
 
 func (f *Font) DrawText(rt *ebiten.Image, str string, ox, oy, scale int, c color.Color) {
@@ -20,6 +20,7 @@ import (
 
 type Input struct {
 	mouseState int
+	keyStates  [ebiten.KeyMax + 1]int
 }
 
 func New() *Input {
@@ -32,8 +33,19 @@ func (i *Input) Update() {
 	} else {
 		i.mouseState = 0
 	}
+	for k := range i.keyStates {
+		if ebiten.IsKeyPressed(ebiten.Key(k)) {
+			i.keyStates[k]++
+		} else {
+			i.keyStates[k] = 0
+		}
+	}
 }
 
 func (i *Input) IsTriggered() bool {
 	return i.mouseState == 1
 }
+
+func (i *Input) IsKeyTriggered(key ebiten.Key) bool {
+	return i.keyStates[key] == 1
+}
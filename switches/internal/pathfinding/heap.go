@@ -0,0 +1,64 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathfinding
+
+type node struct {
+	index int32
+	// score is the cost from the start plus the estimated cost to the goal.
+	score int32
+}
+
+// nodeHeap is a binary min-heap of nodes by score.
+// nodeHeap doesn't use container/heap to avoid the interface conversions.
+type nodeHeap []node
+
+func (h *nodeHeap) push(n node) {
+	*h = append(*h, n)
+	ns := *h
+	i := len(ns) - 1
+	for 0 < i {
+		p := (i - 1) / 2
+		if ns[p].score <= ns[i].score {
+			break
+		}
+		ns[p], ns[i] = ns[i], ns[p]
+		i = p
+	}
+}
+
+func (h *nodeHeap) pop() node {
+	ns := *h
+	n := ns[0]
+	last := len(ns) - 1
+	ns[0] = ns[last]
+	ns = ns[:last]
+	i := 0
+	for {
+		c := 2*i + 1
+		if len(ns) <= c {
+			break
+		}
+		if c+1 < len(ns) && ns[c+1].score < ns[c].score {
+			c++
+		}
+		if ns[i].score <= ns[c].score {
+			break
+		}
+		ns[i], ns[c] = ns[c], ns[i]
+		i = c
+	}
+	*h = ns
+	return n
+}
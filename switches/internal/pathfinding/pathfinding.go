@@ -0,0 +1,193 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pathfinding finds shortest paths on 3D grids of tiles with A*.
+package pathfinding
+
+import (
+	"sync"
+)
+
+// Pos is a tile position. Z is the floor.
+type Pos struct {
+	X, Y, Z int
+}
+
+// Edge is a move to an adjacent tile.
+type Edge struct {
+	// To is the position after the move.
+	To Pos
+
+	// Cost is the cost of the move.
+	Cost int
+
+	// Move identifies the move for the graph's user, e.g. a direction.
+	Move int
+}
+
+// Graph is a 3D grid of tiles to search.
+type Graph interface {
+	// Size returns the width, the height and the depth of the grid.
+	Size() (int, int, int)
+
+	// AppendEdges appends the moves from p to edges and returns the extended slice.
+	// The cost of a move from p to q must be at least |q.X-p.X| + |q.Y-p.Y| + StairsCost()*|q.Z-p.Z|.
+	// For example, a move onto stairs changes X or Y by 1 and Z by 1, so its cost must be at least 1+StairsCost().
+	AppendEdges(edges []Edge, p Pos) []Edge
+
+	// StairsCost returns the extra cost of changing floors, which the heuristic counts per floor.
+	StairsCost() int
+}
+
+// Searcher searches paths on graphs.
+// Searcher keeps its buffers between searches, so reusing a Searcher avoids allocations.
+// Searcher is not safe for concurrent use.
+type Searcher struct {
+	// gen is the number of the current search. A tile's values are valid only if its generation is gen.
+	gen     uint32
+	gens    []uint32
+	closed  []bool
+	costs   []int32
+	parents []int32
+	moves   []int32
+
+	open  nodeHeap
+	edges []Edge
+}
+
+var searcherPool = sync.Pool{
+	New: func() any {
+		return &Searcher{}
+	},
+}
+
+// Search finds a path from start to goal with a pooled Searcher.
+func Search(g Graph, start, goal Pos) ([]Edge, bool) {
+	s := searcherPool.Get().(*Searcher)
+	defer searcherPool.Put(s)
+	return s.Search(g, start, goal)
+}
+
+// Search returns the moves of a least-cost path from start to goal.
+// Search returns false if there is no path or if start or goal is out of the graph.
+func (s *Searcher) Search(g Graph, start, goal Pos) ([]Edge, bool) {
+	w, h, d := g.Size()
+	in := func(p Pos) bool {
+		return 0 <= p.X && p.X < w && 0 <= p.Y && p.Y < h && 0 <= p.Z && p.Z < d
+	}
+	if !in(start) || !in(goal) {
+		return nil, false
+	}
+	if start == goal {
+		return []Edge{}, true
+	}
+	s.reset(w * h * d)
+
+	stairsCost := g.StairsCost()
+	heuristic := func(p Pos) int32 {
+		return int32(abs(p.X-goal.X) + abs(p.Y-goal.Y) + stairsCost*abs(p.Z-goal.Z))
+	}
+	index := func(p Pos) int32 {
+		return int32(p.X + p.Y*w + p.Z*w*h)
+	}
+	pos := func(i int32) Pos {
+		return Pos{int(i) % w, int(i) / w % h, int(i) / (w * h)}
+	}
+
+	si, gi := index(start), index(goal)
+	s.visit(si, 0, si, 0)
+	s.open.push(node{index: si, score: heuristic(start)})
+	for 0 < len(s.open) {
+		n := s.open.pop()
+		if s.closed[n.index] {
+			continue
+		}
+		// As the heuristic is consistent with the costs of the moves, the cost of a tile is final when the tile is
+		// popped first. Then the path to the goal is final here.
+		if n.index == gi {
+			return s.path(si, gi, pos), true
+		}
+		s.closed[n.index] = true
+
+		p := pos(n.index)
+		s.edges = g.AppendEdges(s.edges[:0], p)
+		for _, e := range s.edges {
+			if !in(e.To) {
+				continue
+			}
+			i := index(e.To)
+			c := s.costs[n.index] + int32(e.Cost)
+			if s.gens[i] == s.gen && (s.closed[i] || s.costs[i] <= c) {
+				continue
+			}
+			s.visit(i, c, n.index, int32(e.Move))
+			s.open.push(node{index: i, score: c + heuristic(e.To)})
+		}
+	}
+	return nil, false
+}
+
+func (s *Searcher) reset(n int) {
+	if len(s.gens) < n {
+		s.gens = make([]uint32, n)
+		s.closed = make([]bool, n)
+		s.costs = make([]int32, n)
+		s.parents = make([]int32, n)
+		s.moves = make([]int32, n)
+		s.gen = 0
+	}
+	s.gen++
+	// Clear the generations when the counter wraps around.
+	if s.gen == 0 {
+		for i := range s.gens {
+			s.gens[i] = 0
+		}
+		s.gen = 1
+	}
+	s.open = s.open[:0]
+}
+
+func (s *Searcher) visit(i, cost, parent, move int32) {
+	if s.gens[i] != s.gen {
+		s.gens[i] = s.gen
+		s.closed[i] = false
+	}
+	s.costs[i] = cost
+	s.parents[i] = parent
+	s.moves[i] = move
+}
+
+func (s *Searcher) path(start, goal int32, pos func(int32) Pos) []Edge {
+	n := 0
+	for i := goal; i != start; i = s.parents[i] {
+		n++
+	}
+	path := make([]Edge, n)
+	for i := goal; i != start; i = s.parents[i] {
+		n--
+		path[n] = Edge{
+			To:   pos(i),
+			Cost: int(s.costs[i] - s.costs[s.parents[i]]),
+			Move: int(s.moves[i]),
+		}
+	}
+	return path
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,376 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pathfinding_test
+
+import (
+	"container/heap"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/pathfinding"
+)
+
+// gridGraph is a random grid. Stairs tiles move the player to the other floor, as in the game.
+type gridGraph struct {
+	width, height, depth int
+	stairsCost           int
+	// tiles are 0 for walls, 1 for floors, 2 for upstairs and 3 for downstairs.
+	tiles []int
+	// costs are the extra costs of entering the tiles.
+	costs []int
+}
+
+func newGridGraph(r *rand.Rand, width, height, depth, stairsCost int) *gridGraph {
+	g := &gridGraph{
+		width:      width,
+		height:     height,
+		depth:      depth,
+		stairsCost: stairsCost,
+		tiles:      make([]int, width*height*depth),
+		costs:      make([]int, width*height*depth),
+	}
+	for i := range g.tiles {
+		switch v := r.IntN(20); {
+		case v < 5:
+		case v == 5:
+			g.tiles[i] = 2
+		case v == 6:
+			g.tiles[i] = 3
+		default:
+			g.tiles[i] = 1
+		}
+		g.costs[i] = r.IntN(3)
+	}
+	return g
+}
+
+func (g *gridGraph) Size() (int, int, int) {
+	return g.width, g.height, g.depth
+}
+
+func (g *gridGraph) StairsCost() int {
+	return g.stairsCost
+}
+
+func (g *gridGraph) index(p pathfinding.Pos) int {
+	return p.X + p.Y*g.width + p.Z*g.width*g.height
+}
+
+func (g *gridGraph) AppendEdges(edges []pathfinding.Edge, p pathfinding.Pos) []pathfinding.Edge {
+	for i, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		to := pathfinding.Pos{X: p.X + d[0], Y: p.Y + d[1], Z: p.Z}
+		if to.X < 0 || g.width <= to.X || to.Y < 0 || g.height <= to.Y {
+			continue
+		}
+		cost := 1 + g.costs[g.index(to)]
+		switch g.tiles[g.index(to)] {
+		case 0:
+			continue
+		case 2:
+			to.Z--
+			cost += g.stairsCost
+		case 3:
+			to.Z++
+			cost += g.stairsCost
+		}
+		if to.Z < 0 || g.depth <= to.Z || g.tiles[g.index(to)] == 0 {
+			continue
+		}
+		edges = append(edges, pathfinding.Edge{To: to, Cost: cost, Move: i})
+	}
+	return edges
+}
+
+// fieldGraph is the graph of the tiles of a field the player can walk through without toggling switches.
+type fieldGraph struct {
+	field *core.Field
+	state core.State
+}
+
+func newFieldGraph(f *core.Field, switchStates []bool) *fieldGraph {
+	return &fieldGraph{
+		field: f,
+		state: core.State{SwitchStates: switchStates},
+	}
+}
+
+func (g *fieldGraph) Size() (int, int, int) {
+	return g.field.TileSize()
+}
+
+func (g *fieldGraph) StairsCost() int {
+	return 4
+}
+
+func (g *fieldGraph) AppendEdges(edges []pathfinding.Edge, p pathfinding.Pos) []pathfinding.Edge {
+	for _, d := range []core.Dir{core.DirLeft, core.DirRight, core.DirUp, core.DirDown} {
+		g.state.X, g.state.Y, g.state.Z = p.X, p.Y, p.Z
+		if _, _, ok := g.state.Next(g.field, d); !ok {
+			continue
+		}
+		if _, sw := g.state.Move(g.field, d); sw {
+			continue
+		}
+		to := pathfinding.Pos{X: g.state.X, Y: g.state.Y, Z: g.state.Z}
+		cost := 1
+		if to.Z != p.Z {
+			cost += g.StairsCost()
+		}
+		edges = append(edges, pathfinding.Edge{To: to, Cost: cost, Move: int(d)})
+	}
+	return edges
+}
+
+type item struct {
+	pos  pathfinding.Pos
+	cost int
+}
+
+type items []item
+
+func (is items) Len() int           { return len(is) }
+func (is items) Less(i, j int) bool { return is[i].cost < is[j].cost }
+func (is items) Swap(i, j int)      { is[i], is[j] = is[j], is[i] }
+func (is *items) Push(x any)        { *is = append(*is, x.(item)) }
+func (is *items) Pop() any {
+	old := *is
+	x := old[len(old)-1]
+	*is = old[:len(old)-1]
+	return x
+}
+
+// dijkstra returns the least costs from start to all the reachable positions by Dijkstra's algorithm.
+func dijkstra(g pathfinding.Graph, start pathfinding.Pos) map[pathfinding.Pos]int {
+	costs := map[pathfinding.Pos]int{start: 0}
+	done := map[pathfinding.Pos]bool{}
+	open := &items{{start, 0}}
+	var edges []pathfinding.Edge
+	for 0 < open.Len() {
+		it := heap.Pop(open).(item)
+		if done[it.pos] {
+			continue
+		}
+		done[it.pos] = true
+		edges = g.AppendEdges(edges[:0], it.pos)
+		for _, e := range edges {
+			c := it.cost + e.Cost
+			if old, ok := costs[e.To]; ok && old <= c {
+				continue
+			}
+			costs[e.To] = c
+			heap.Push(open, item{e.To, c})
+		}
+	}
+	return costs
+}
+
+// checkPath checks that the path is made of the graph's edges from start to goal and that its cost is want.
+func checkPath(t *testing.T, g pathfinding.Graph, start, goal pathfinding.Pos, path []pathfinding.Edge, want int) {
+	t.Helper()
+	p := start
+	cost := 0
+	for i, e := range path {
+		found := false
+		for _, e2 := range g.AppendEdges(nil, p) {
+			if e2 == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("%v -> %v: edge %d (%+v) is not in the graph", start, goal, i, e)
+		}
+		p = e.To
+		cost += e.Cost
+	}
+	if p != goal {
+		t.Fatalf("%v -> %v: the path ends at %v", start, goal, p)
+	}
+	if cost != want {
+		t.Errorf("%v -> %v: cost: got %d, want %d", start, goal, cost, want)
+	}
+}
+
+// checkSearch compares the paths from start to all the positions with Dijkstra's algorithm.
+func checkSearch(t *testing.T, s *pathfinding.Searcher, g pathfinding.Graph, start pathfinding.Pos) {
+	t.Helper()
+	costs := dijkstra(g, start)
+	w, h, d := g.Size()
+	for z := 0; z < d; z++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				goal := pathfinding.Pos{X: x, Y: y, Z: z}
+				path, ok := s.Search(g, start, goal)
+				want, reachable := costs[goal]
+				if ok != reachable {
+					t.Fatalf("%v -> %v: got %v, want %v", start, goal, ok, reachable)
+				}
+				if ok {
+					checkPath(t, g, start, goal, path, want)
+				}
+			}
+		}
+	}
+}
+
+func TestSearchGrid(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	var s pathfinding.Searcher
+	for i := 0; i < 20; i++ {
+		g := newGridGraph(r, 12, 10, 3, r.IntN(6))
+		start := pathfinding.Pos{X: r.IntN(g.width), Y: r.IntN(g.height), Z: r.IntN(g.depth)}
+		checkSearch(t, &s, g, start)
+	}
+}
+
+func TestSearchField(t *testing.T) {
+	for seed := uint64(0); seed < 4; seed++ {
+		f, err := core.NewField(3, 3, 3, 3, seed, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := core.NewState(f)
+		checkSearch(t, &pathfinding.Searcher{}, newFieldGraph(f, s.SwitchStates), pathfinding.Pos{X: s.X, Y: s.Y, Z: s.Z})
+	}
+}
+
+func TestSearchOutOfGraph(t *testing.T) {
+	g := newGridGraph(rand.New(rand.NewPCG(1, 2)), 4, 4, 1, 0)
+	for _, p := range []pathfinding.Pos{{-1, 0, 0}, {4, 0, 0}, {0, 0, 1}} {
+		if _, ok := pathfinding.Search(g, p, pathfinding.Pos{}); ok {
+			t.Errorf("Search from %v must fail", p)
+		}
+		if _, ok := pathfinding.Search(g, pathfinding.Pos{}, p); ok {
+			t.Errorf("Search to %v must fail", p)
+		}
+	}
+}
+
+// benchmarkField returns an EXTREME field, a state on its solution and the farthest position from the state.
+// The state is chosen among some states on the solution so that the path is long.
+func benchmarkField(b *testing.B) (*core.Field, *core.State, pathfinding.Pos) {
+	var d *core.Difficulty
+	for _, d2 := range core.Difficulties {
+		if d2.Name == "EXTREME" {
+			d = d2
+		}
+	}
+	f, err := d.NewField(1)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sol, err := core.Solve(f, core.NewState(f))
+	if err != nil {
+		b.Fatal(err)
+	}
+	var start *core.State
+	var goal pathfinding.Pos
+	farthest := -1
+	s := core.NewState(f)
+	for i, m := range sol.Moves {
+		if i%10 == 0 {
+			for p, c := range dijkstra(newFieldGraph(f, s.SwitchStates), pathfinding.Pos{X: s.X, Y: s.Y, Z: s.Z}) {
+				if farthest < c {
+					start, goal, farthest = s.Clone(), p, c
+				}
+			}
+		}
+		s.Step(f, m)
+	}
+	return f, start, goal
+}
+
+func BenchmarkSearch(b *testing.B) {
+	f, s, goal := benchmarkField(b)
+	g := newFieldGraph(f, s.SwitchStates)
+	start := pathfinding.Pos{X: s.X, Y: s.Y, Z: s.Z}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := pathfinding.Search(g, start, goal); !ok {
+			b.Fatal("no path")
+		}
+	}
+}
+
+// BenchmarkBFS measures the breadth-first search which the game used before the A* search, for comparison.
+func BenchmarkBFS(b *testing.B) {
+	f, s, goal := benchmarkField(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if bfs(f, s, goal) == nil {
+			b.Fatal("no path")
+		}
+	}
+}
+
+// bfs is the former breadth-first search of CalcPath3D without the passable callback.
+func bfs(f *core.Field, s *core.State, goal pathfinding.Pos) []core.Dir {
+	type step struct {
+		parent pathfinding.Pos
+		dir    core.Dir
+	}
+	start := pathfinding.Pos{X: s.X, Y: s.Y, Z: s.Z}
+	current := []pathfinding.Pos{start}
+	steps := map[pathfinding.Pos]step{start: {}}
+	st := &core.State{SwitchStates: s.SwitchStates}
+	found := false
+	for 0 < len(current) && !found {
+		next := []pathfinding.Pos{}
+		for _, p := range current {
+			for _, d := range []core.Dir{core.DirLeft, core.DirRight, core.DirUp, core.DirDown} {
+				st.X, st.Y, st.Z = p.X, p.Y, p.Z
+				nx, ny, ok := st.Next(f, d)
+				if !ok {
+					continue
+				}
+				entered := pathfinding.Pos{X: nx, Y: ny, Z: p.Z}
+				_, sw := st.Move(f, d)
+				n := pathfinding.Pos{X: st.X, Y: st.Y, Z: st.Z}
+				if _, ok := steps[n]; ok {
+					continue
+				}
+				if sw && entered != goal {
+					continue
+				}
+				steps[n] = step{p, d}
+				if entered == goal || n == goal {
+					goal = n
+					found = true
+					break
+				}
+				next = append(next, n)
+			}
+			if found {
+				break
+			}
+		}
+		current = next
+	}
+	if !found {
+		return nil
+	}
+	dirs := []core.Dir{}
+	for p := goal; p != start; p = steps[p].parent {
+		dirs = append(dirs, steps[p].dir)
+	}
+	path := make([]core.Dir, len(dirs))
+	for i, d := range dirs {
+		path[len(dirs)-i-1] = d
+	}
+	return path
+}
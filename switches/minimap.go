@@ -0,0 +1,160 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/font"
+)
+
+var (
+	minimapRoomColor       = color.RGBA{0x75, 0x75, 0x75, 0xff}
+	minimapSwitchRoomColor = color.RGBA{0x4e, 0x6c, 0xef, 0xff}
+	minimapGoalRoomColor   = color.RGBA{0x66, 0xbb, 0x6a, 0xff}
+	minimapUpstairsColor   = color.RGBA{0xee, 0xee, 0xee, 0xff}
+	minimapDownstairsColor = color.RGBA{0x21, 0x21, 0x21, 0xff}
+	minimapPlayerColor     = color.RGBA{0xff, 0xee, 0x58, 0xff}
+)
+
+const (
+	minimapMaxCellSize = 24
+	minimapTop         = 24
+	minimapBottom      = screenHeight - 24
+)
+
+// minimap shows the rooms of a floor. With the fog of war, only the discovered rooms and passages are shown.
+type minimap struct {
+	scene *gameScene
+	floor int
+}
+
+func newMinimap(scene *gameScene) *minimap {
+	return &minimap{
+		scene: scene,
+		floor: scene.state.Z,
+	}
+}
+
+// update updates the minimap and returns false when the minimap is closed.
+func (m *minimap) update() bool {
+	input := m.scene.game.input
+	if input.IsKeyTriggered(ebiten.KeyM) || input.IsKeyTriggered(ebiten.KeyEscape) {
+		return false
+	}
+	if input.IsKeyTriggered(ebiten.KeyBracketLeft) && 0 < m.floor {
+		m.floor--
+	}
+	if input.IsKeyTriggered(ebiten.KeyBracketRight) && m.floor < m.scene.field.Depth()-1 {
+		m.floor++
+	}
+	// Clicking a room travels to the center of the room.
+	if input.IsTriggered() {
+		f := m.scene.field
+		c, ox, oy := m.cellSize()
+		cx, cy := ebiten.CursorPosition()
+		if cx < ox || cy < oy {
+			return true
+		}
+		x, y := (cx-ox)/c, (cy-oy)/c
+		if _, ok := f.Room(x, y, m.floor); !ok || !m.scene.exploration.roomKnown(x, y, m.floor) {
+			return true
+		}
+		tx, ty := f.RoomCenter(x, y)
+		if m.scene.travel(tx, ty, m.floor) {
+			return false
+		}
+	}
+	return true
+}
+
+// cellSize returns the size of a room on the map and the position of the room (0, 0).
+func (m *minimap) cellSize() (int, int, int) {
+	f := m.scene.field
+	// The extra row is for the goal room.
+	w, h := f.Width(), f.Height()+1
+	c := min(minimapMaxCellSize, min((screenWidth-16)/w, (minimapBottom-minimapTop)/h))
+	return c, (screenWidth - c*w) / 2, minimapTop + (minimapBottom-minimapTop-c*h)/2
+}
+
+func (m *minimap) draw(screen *ebiten.Image) {
+	if emptyImage == nil {
+		emptyImage = ebiten.NewImage(screenWidth, screenHeight)
+		emptyImage.Fill(color.RGBA{0, 0, 0, 0x80})
+	}
+	screen.DrawImage(emptyImage, nil)
+
+	f := m.scene.field
+	c, ox, oy := m.cellSize()
+	margin := max(1, c/6)
+	thickness := max(1, c/4)
+	px, py := f.RoomPosition(m.scene.state.X, m.scene.state.Y)
+	e := m.scene.exploration
+	for y := 0; y <= f.Height(); y++ {
+		for x := 0; x < f.Width(); x++ {
+			r, ok := f.Room(x, y, m.floor)
+			if !ok {
+				continue
+			}
+			// A known passage is drawn to the edge of the room even if the room beyond it is unknown.
+			rx, ry := float32(ox+x*c), float32(oy+y*c)
+			center := float32(c-thickness) / 2
+			for _, d := range []core.Dir{core.DirLeft, core.DirRight, core.DirUp, core.DirDown} {
+				if !r.Passages[d] || !e.passageKnown(x, y, m.floor, d) {
+					continue
+				}
+				switch d {
+				case core.DirLeft:
+					vector.DrawFilledRect(screen, rx, ry+center, float32(c/2), float32(thickness), minimapRoomColor, false)
+				case core.DirRight:
+					vector.DrawFilledRect(screen, rx+float32(c/2), ry+center, float32(c-c/2), float32(thickness), minimapRoomColor, false)
+				case core.DirUp:
+					vector.DrawFilledRect(screen, rx+center, ry, float32(thickness), float32(c/2), minimapRoomColor, false)
+				case core.DirDown:
+					vector.DrawFilledRect(screen, rx+center, ry+float32(c/2), float32(thickness), float32(c-c/2), minimapRoomColor, false)
+				}
+			}
+			if !e.roomKnown(x, y, m.floor) {
+				continue
+			}
+			clr := minimapRoomColor
+			switch {
+			case r.Goal:
+				clr = minimapGoalRoomColor
+			case len(r.Switches) > 0:
+				clr = minimapSwitchRoomColor
+			}
+			size := float32(c - 2*margin)
+			vector.DrawFilledRect(screen, rx+float32(margin), ry+float32(margin), size, size, clr, false)
+			stairs := float32(max(2, c/4))
+			if r.Passages[core.DirUpstairs] {
+				vector.DrawFilledRect(screen, rx+float32(margin), ry+float32(margin), stairs, stairs, minimapUpstairsColor, false)
+			}
+			if r.Passages[core.DirDownstairs] {
+				vector.DrawFilledRect(screen, rx+float32(margin)+size-stairs, ry+float32(margin)+size-stairs, stairs, stairs, minimapDownstairsColor, false)
+			}
+			if x == px && y == py && m.floor == m.scene.state.Z {
+				vector.StrokeRect(screen, rx+float32(margin), ry+float32(margin), size, size, 2, minimapPlayerColor, false)
+			}
+		}
+	}
+
+	font.ArcadeFont.DrawTextWithShadow(screen, floorName(m.floor), 8, 8, 1, color.White)
+	font.ArcadeFont.DrawTextWithShadow(screen, "[ ] FLOOR  M CLOSE  CLICK GO", 8, screenHeight-16, 1, color.White)
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+// saveVersion is the version of the save data format.
+// Version 1 had the field parameters and the seed instead of the field.
+const saveVersion = 2
+
+var (
+	errSaveCorrupted    = errors.New("switches: save data is corrupted")
+	errSaveIncompatible = errors.New("switches: save data is incompatible")
+)
+
+type saveData struct {
+	Version      int           `json:"version"`
+	Field        *core.Field   `json:"field"`
+	X            int           `json:"x"`
+	Y            int           `json:"y"`
+	Z            int           `json:"z"`
+	Dir          core.Dir      `json:"dir"`
+	SwitchStates []bool        `json:"switchStates"`
+	Steps        int           `json:"steps"`
+	Flips        int           `json:"flips"`
+	Undos        int           `json:"undos"`
+	Hints        int           `json:"hints"`
+	Elapsed      time.Duration `json:"elapsed"`
+	// Difficulty is nil if the field is not generated.
+	Difficulty *core.Difficulty `json:"difficulty,omitempty"`
+	// Seed is the seed of the generated field, as the field's encoding doesn't have it.
+	Seed uint64 `json:"seed,omitempty"`
+	// ExploredRooms and ExploredPassages are the discovered rooms and passages with the fog of war.
+	Fog              bool     `json:"fog,omitempty"`
+	ExploredRooms    [][3]int `json:"exploredRooms,omitempty"`
+	ExploredPassages [][4]int `json:"exploredPassages,omitempty"`
+}
+
+func saveFilePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "switches", "save.json"), nil
+}
+
+func hasSaveFile() bool {
+	path, err := saveFilePath()
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+func removeSaveFile() error {
+	path, err := saveFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *gameScene) save() error {
+	path, err := saveFilePath()
+	if err != nil {
+		return err
+	}
+	b, err := s.marshalSaveData()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write to a temporary file first so that a failure doesn't break the existing save data.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	s.ownsSave = true
+	return nil
+}
+
+func (s *gameScene) marshalSaveData() ([]byte, error) {
+	d := &saveData{
+		Version:      saveVersion,
+		Field:        s.field,
+		X:            s.state.X,
+		Y:            s.state.Y,
+		Z:            s.state.Z,
+		Dir:          s.state.Dir,
+		SwitchStates: s.state.SwitchStates,
+		Steps:        s.state.Steps,
+		Flips:        s.state.Flips,
+		Undos:        s.undos,
+		Hints:        s.hints,
+		Elapsed:      time.Since(s.startTime),
+		Difficulty:   s.difficulty,
+		Seed:         s.seed,
+	}
+	if s.exploration.fog {
+		d.Fog = true
+		d.ExploredRooms = s.exploration.roomList()
+		d.ExploredPassages = s.exploration.passageList()
+	}
+	return json.Marshal(d)
+}
+
+func loadGameScene(game *Game) (*gameScene, error) {
+	path, err := saveFilePath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := unmarshalSaveData(game, b)
+	if err != nil {
+		return nil, err
+	}
+	s.ownsSave = true
+	return s, nil
+}
+
+// unmarshalSaveData returns the game scene of the save data b.
+// unmarshalSaveData returns an error wrapping errSaveIncompatible or errSaveCorrupted if b is not valid save data.
+func unmarshalSaveData(game *Game, b []byte) (*gameScene, error) {
+	// Check the version first, as the other fields might have different meanings in other versions.
+	var v struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("%w: %v", errSaveCorrupted, err)
+	}
+	if v.Version != saveVersion {
+		return nil, fmt.Errorf("%w: version %d is not supported", errSaveIncompatible, v.Version)
+	}
+	var d saveData
+	if err := json.Unmarshal(b, &d); err != nil {
+		return nil, fmt.Errorf("%w: %v", errSaveCorrupted, err)
+	}
+	if d.Field == nil {
+		return nil, fmt.Errorf("%w: no field", errSaveCorrupted)
+	}
+	s := newGameScene(d.Field, d.Difficulty, game)
+	w, h, depth := s.field.TileSize()
+	if d.X < 0 || w <= d.X || d.Y < 0 || h <= d.Y || d.Z < 0 || depth <= d.Z {
+		return nil, fmt.Errorf("%w: the player is out of the field", errSaveCorrupted)
+	}
+	if len(d.SwitchStates) != s.field.Switches() {
+		return nil, fmt.Errorf("%w: the number of switch states doesn't match", errSaveCorrupted)
+	}
+	if d.Dir < core.DirLeft || core.DirDown < d.Dir {
+		return nil, fmt.Errorf("%w: invalid direction: %d", errSaveCorrupted, d.Dir)
+	}
+	if t, _ := s.field.Tile(d.X, d.Y, d.Z, d.SwitchStates); !t.IsPassable() {
+		return nil, fmt.Errorf("%w: the player is not on a passable tile", errSaveCorrupted)
+	}
+	if d.Steps < 0 || d.Flips < 0 || d.Undos < 0 || d.Hints < 0 || d.Elapsed < 0 {
+		return nil, fmt.Errorf("%w: negative counts", errSaveCorrupted)
+	}
+	e := newExploration(d.Fog && s.field.HasRooms())
+	for _, p := range d.ExploredRooms {
+		if _, ok := s.field.Room(p[0], p[1], p[2]); !ok {
+			return nil, fmt.Errorf("%w: invalid explored room: %v", errSaveCorrupted, p)
+		}
+		e.rooms[roomPos{p[0], p[1], p[2]}] = struct{}{}
+	}
+	for _, p := range d.ExploredPassages {
+		r, ok := s.field.Room(p[0], p[1], p[2])
+		if !ok || p[3] < 0 || len(r.Passages) <= p[3] || !r.Passages[p[3]] {
+			return nil, fmt.Errorf("%w: invalid explored passage: %v", errSaveCorrupted, p)
+		}
+		e.passages[passagePos{roomPos{p[0], p[1], p[2]}, core.Dir(p[3])}] = struct{}{}
+	}
+	e.visit(s.field, d.X, d.Y, d.Z)
+	s.exploration = e
+
+	s.state.X = d.X
+	s.state.Y = d.Y
+	s.state.Z = d.Z
+	s.state.Dir = d.Dir
+	s.state.SwitchStates = d.SwitchStates
+	s.state.Steps = d.Steps
+	s.state.Flips = d.Flips
+	s.undos = d.Undos
+	s.hints = d.Hints
+	s.seed = d.Seed
+	s.startTime = time.Now().Add(-d.Elapsed)
+	return s, nil
+}
+
+// saveErrorMessage returns a message for the player about the error loading the save data.
+func saveErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "NO SAVE DATA"
+	case errors.Is(err, errSaveIncompatible):
+		return "SAVE DATA IS INCOMPATIBLE"
+	case errors.Is(err, errSaveCorrupted):
+		return "SAVE DATA IS BROKEN"
+	}
+	return "CAN'T LOAD SAVE DATA"
+}
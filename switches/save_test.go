@@ -0,0 +1,220 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/hajimehoshi/switches/switches/core"
+)
+
+// newTestSaveData returns the save data of a game in progress as a JSON object.
+func newTestSaveData(t *testing.T) (*Game, map[string]any) {
+	t.Helper()
+	game := &Game{fog: true}
+	d := core.Difficulties[0]
+	f, err := d.NewField(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newGameScene(f, d, game)
+	for _, m := range s.par.Moves[:len(s.par.Moves)/2] {
+		if !s.state.Step(f, m) {
+			t.Fatalf("move %v is not possible", m)
+		}
+		s.exploration.visit(f, s.state.X, s.state.Y, s.state.Z)
+	}
+	s.undos = 2
+	s.hints = 1
+	b, err := s.marshalSaveData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return game, m
+}
+
+func TestSaveDataRoundTrip(t *testing.T) {
+	game, m := newTestSaveData(t)
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := unmarshalSaveData(game, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b2, err := s.marshalSaveData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 map[string]any
+	if err := json.Unmarshal(b2, &m2); err != nil {
+		t.Fatal(err)
+	}
+	// The elapsed time goes on while the test runs.
+	delete(m, "elapsed")
+	delete(m2, "elapsed")
+	if !reflect.DeepEqual(m, m2) {
+		t.Errorf("got %v, want %v", m2, m)
+	}
+}
+
+func TestSaveDataCorrupted(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(m map[string]any)
+		want   error
+	}{
+		{
+			name:   "old version",
+			modify: func(m map[string]any) { m["version"] = saveVersion - 1 },
+			want:   errSaveIncompatible,
+		},
+		{
+			name:   "no field",
+			modify: func(m map[string]any) { delete(m, "field") },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "broken field",
+			modify: func(m map[string]any) { m["field"] = map[string]any{"version": 3, "width": -1} },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "out of the field",
+			modify: func(m map[string]any) { m["x"] = -1 },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "out of the floors",
+			modify: func(m map[string]any) { m["z"] = 100 },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "too few switch states",
+			modify: func(m map[string]any) { m["switchStates"] = []bool{} },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "invalid direction",
+			modify: func(m map[string]any) { m["dir"] = 100 },
+			want:   errSaveCorrupted,
+		},
+		{
+			name: "not on a passable tile",
+			modify: func(m map[string]any) {
+				m["x"] = 0
+				m["y"] = 0
+				m["z"] = 0
+			},
+			want: errSaveCorrupted,
+		},
+		{
+			name:   "negative steps",
+			modify: func(m map[string]any) { m["steps"] = -1 },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "invalid explored room",
+			modify: func(m map[string]any) { m["exploredRooms"] = [][3]int{{100, 0, 0}} },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "invalid explored passage",
+			modify: func(m map[string]any) { m["exploredPassages"] = [][4]int{{0, 0, 0, 6}} },
+			want:   errSaveCorrupted,
+		},
+		{
+			name:   "wrong type",
+			modify: func(m map[string]any) { m["steps"] = "many" },
+			want:   errSaveCorrupted,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			game, m := newTestSaveData(t)
+			tc.modify(m)
+			b, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := unmarshalSaveData(game, b); !errors.Is(err, tc.want) {
+				t.Errorf("got %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSaveDataNotJSON(t *testing.T) {
+	for _, b := range []string{"", "{", "null", `{"version": 2`} {
+		if _, err := unmarshalSaveData(&Game{}, []byte(b)); !errors.Is(err, errSaveCorrupted) && !errors.Is(err, errSaveIncompatible) {
+			t.Errorf("%q: got %v, want an error of the save data", b, err)
+		}
+	}
+}
+
+// useTempSaveDir makes the save data go to a temporary directory.
+func useTempSaveDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	t.Setenv("AppData", dir)
+}
+
+func TestGoalRemovesOwnSave(t *testing.T) {
+	useTempSaveDir(t)
+	game := &Game{}
+	f, err := core.Difficulties[0].NewField(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved := newGameScene(f, core.Difficulties[0], game)
+	if err := saved.save(); err != nil {
+		t.Fatal(err)
+	}
+	// Finishing another game keeps the save data.
+	newGameScene(f, core.Difficulties[0], game).reachGoal()
+	if !hasSaveFile() {
+		t.Fatalf("finishing a new game removed the save data")
+	}
+
+	// Finishing the continued game removes the save data.
+	continued, err := loadGameScene(game)
+	if err != nil {
+		t.Fatal(err)
+	}
+	continued.reachGoal()
+	if hasSaveFile() {
+		t.Errorf("finishing the continued game didn't remove the save data")
+	}
+
+	// Finishing the game that wrote the save data removes it.
+	if err := saved.save(); err != nil {
+		t.Fatal(err)
+	}
+	saved.reachGoal()
+	if hasSaveFile() {
+		t.Errorf("finishing the saved game didn't remove the save data")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"image/color"
+	"log"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/hajimehoshi/switches/switches/internal/font"
+)
+
+type settingsItem struct {
+	text string
+	// tilesetID is empty for the other items.
+	tilesetID string
+	// fog is true for the item to toggle the fog of war.
+	fog bool
+	x   int
+	y   int
+}
+
+func (i *settingsItem) size() (int, int) {
+	return font.ArcadeFont.TextWidth(i.text), font.ArcadeFont.TextHeight(i.text)
+}
+
+type settingsScene struct {
+	game         *Game
+	items        []*settingsItem
+	selectedItem *settingsItem
+	message      string
+}
+
+func newSettingsScene(game *Game) *settingsScene {
+	s := &settingsScene{
+		game: game,
+	}
+	ids, err := tilesetIDs()
+	if err != nil {
+		log.Printf("switches: failed to list the tilesets: %v", err)
+		s.message = "CAN'T LIST TILESETS"
+	}
+	y := 112
+	for _, id := range ids {
+		text := id
+		// Loading a tileset to show its name is fine, as the images are cached.
+		if t, err := loadTileset(id); err == nil {
+			text = t.name
+		}
+		s.items = append(s.items, &settingsItem{
+			text:      text,
+			tilesetID: id,
+			x:         64,
+			y:         y,
+		})
+		y += 16
+	}
+	s.items = append(s.items, &settingsItem{
+		text: fogText(game.fog),
+		fog:  true,
+		x:    64,
+		y:    y + 8,
+	})
+	s.items = append(s.items, &settingsItem{
+		text: "BACK",
+		x:    64,
+		y:    y + 24,
+	})
+	return s
+}
+
+func fogText(fog bool) string {
+	if fog {
+		return "FOG OF WAR: ON"
+	}
+	return "FOG OF WAR: OFF"
+}
+
+func (s *settingsScene) Update() error {
+	if s.game.input.IsKeyTriggered(ebiten.KeyEscape) {
+		s.game.goTo(newTitleScene(s.game))
+		return nil
+	}
+	s.selectedItem = nil
+	x, y := ebiten.CursorPosition()
+	for _, i := range s.items {
+		w, h := i.size()
+		if i.x <= x && x < i.x+w && i.y <= y && y < i.y+h {
+			s.selectedItem = i
+			break
+		}
+	}
+	if !s.game.input.IsTriggered() || s.selectedItem == nil {
+		return nil
+	}
+	if s.selectedItem.fog {
+		s.game.fog = !s.game.fog
+		s.selectedItem.text = fogText(s.game.fog)
+		return nil
+	}
+	if s.selectedItem.tilesetID == "" {
+		s.game.goTo(newTitleScene(s.game))
+		return nil
+	}
+	t, err := loadTileset(s.selectedItem.tilesetID)
+	if err != nil {
+		log.Printf("switches: failed to load the tileset: %v", err)
+		s.message = "CAN'T LOAD THE TILESET"
+		return nil
+	}
+	s.game.tileset = t
+	s.message = ""
+	return nil
+}
+
+func (s *settingsScene) Draw(screen *ebiten.Image) {
+	screen.Fill(backgroundColor)
+	title := "SETTINGS"
+	w := font.ArcadeFont.TextWidth(title)
+	font.ArcadeFont.DrawText(screen, title, (screenWidth-w*2)/2, 64, 2, color.White)
+	font.ArcadeFont.DrawText(screen, "TILESET", 48, 96, 1, color.White)
+	for _, i := range s.items {
+		clr := color.Color(color.White)
+		if s.selectedItem == i {
+			clr = color.RGBA{0xff, 0xee, 0x58, 0xff}
+		}
+		font.ArcadeFont.DrawText(screen, i.text, i.x, i.y, 1, clr)
+		if i.tilesetID != "" && i.tilesetID == s.game.tileset.id {
+			font.ArcadeFont.DrawText(screen, ">", i.x-12, i.y, 1, clr)
+		}
+	}
+	if s.message != "" {
+		w := font.ArcadeFont.TextWidth(s.message)
+		font.ArcadeFont.DrawText(screen, s.message, (screenWidth-w)/2, screenHeight-24, 1, color.White)
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2016 Hajime Hoshi
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package switches
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"path"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+
+	"github.com/hajimehoshi/switches/switches/core"
+	"github.com/hajimehoshi/switches/switches/internal/assets"
+)
+
+// A tileset is a JSON file in the tilesets directory of the assets, with an image next to it.
+// Each rectangle is [x, y, width, height] in the image, and is scaled to the grid size when drawn.
+//
+//	{
+//	  "name": "DEFAULT",
+//	  "image": "default.png",
+//	  "tiles": {"regular": [16, 0, 16, 16], ...},
+//	  "player": {"left": [0, 16, 16, 16], ...},
+//	  "cursor": [16, 16, 16, 16]
+//	}
+
+const (
+	tilesetDir     = "tilesets"
+	defaultTileset = "default"
+)
+
+var tilesetTileNames = map[string]core.Tile{
+	"regular":             core.TileRegular,
+	"upstairs":            core.TileUpstairs,
+	"downstairs":          core.TileDownstairs,
+	"oneWayLeft":          core.TileOneWayLeft,
+	"oneWayRight":         core.TileOneWayRight,
+	"oneWayUp":            core.TileOneWayUp,
+	"oneWayDown":          core.TileOneWayDown,
+	"oneWayUpstairs":      core.TileOneWayUpstairs,
+	"oneWayDownstairs":    core.TileOneWayDownstairs,
+	"switch0":             core.TileSwitch0,
+	"switch1":             core.TileSwitch1,
+	"switchedTileValid":   core.TileSwitchedTileValid,
+	"switchedTileInvalid": core.TileSwitchedTileInvalid,
+	"goal":                core.TileGoal,
+}
+
+var tilesetDirNames = map[string]core.Dir{
+	"left":  core.DirLeft,
+	"right": core.DirRight,
+	"up":    core.DirUp,
+	"down":  core.DirDown,
+}
+
+type tileset struct {
+	id     string
+	name   string
+	image  *ebiten.Image
+	tiles  map[core.Tile]image.Rectangle
+	player map[core.Dir]image.Rectangle
+	cursor image.Rectangle
+}
+
+type jsonTileset struct {
+	Name   string            `json:"name"`
+	Image  string            `json:"image"`
+	Tiles  map[string][4]int `json:"tiles"`
+	Player map[string][4]int `json:"player"`
+	Cursor [4]int            `json:"cursor"`
+}
+
+// tilesetIDs returns the IDs of the available tilesets. An ID is the file name without the extension.
+func tilesetIDs() ([]string, error) {
+	names, err := assets.ReadDir(tilesetDir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, n := range names {
+		if strings.HasSuffix(n, ".json") {
+			ids = append(ids, strings.TrimSuffix(n, ".json"))
+		}
+	}
+	return ids, nil
+}
+
+func loadTileset(id string) (*tileset, error) {
+	b, err := assets.ReadFile(path.Join(tilesetDir, id+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var j jsonTileset
+	if err := json.Unmarshal(b, &j); err != nil {
+		return nil, fmt.Errorf("switches: tileset %s: %w", id, err)
+	}
+	img, err := assets.Image(path.Join(tilesetDir, j.Image))
+	if err != nil {
+		return nil, fmt.Errorf("switches: tileset %s: %w", id, err)
+	}
+	t := &tileset{
+		id:     id,
+		name:   j.Name,
+		image:  img,
+		tiles:  map[core.Tile]image.Rectangle{},
+		player: map[core.Dir]image.Rectangle{},
+	}
+	if t.name == "" {
+		t.name = strings.ToUpper(id)
+	}
+	rect := func(what string, r [4]int) (image.Rectangle, error) {
+		rect := image.Rect(r[0], r[1], r[0]+r[2], r[1]+r[3])
+		if rect.Empty() || !rect.In(img.Bounds()) {
+			return image.Rectangle{}, fmt.Errorf("switches: tileset %s: invalid rectangle for %s: %v", id, what, r)
+		}
+		return rect, nil
+	}
+	for name, tile := range tilesetTileNames {
+		r, ok := j.Tiles[name]
+		if !ok {
+			return nil, fmt.Errorf("switches: tileset %s: no tile %s", id, name)
+		}
+		if t.tiles[tile], err = rect(name, r); err != nil {
+			return nil, err
+		}
+	}
+	for name, dir := range tilesetDirNames {
+		r, ok := j.Player[name]
+		if !ok {
+			return nil, fmt.Errorf("switches: tileset %s: no player for %s", id, name)
+		}
+		if t.player[dir], err = rect("player "+name, r); err != nil {
+			return nil, err
+		}
+	}
+	if t.cursor, err = rect("cursor", j.Cursor); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// draw draws the part of the tileset image at the rectangle r to the grid at (x, y) on dst.
+func (t *tileset) draw(dst *ebiten.Image, r image.Rectangle, x, y int) {
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(float64(gridSize)/float64(r.Dx()), float64(gridSize)/float64(r.Dy()))
+	op.GeoM.Translate(float64(x), float64(y))
+	dst.DrawImage(t.image.SubImage(r).(*ebiten.Image), op)
+}
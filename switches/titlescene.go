@@ -15,16 +15,36 @@
 package switches
 
 import (
+	"context"
+	"fmt"
 	"image/color"
+	"log"
+	"math/rand/v2"
+	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 
+	"github.com/hajimehoshi/switches/switches/core"
 	"github.com/hajimehoshi/switches/switches/internal/font"
 )
 
+type modeKind int
+
+const (
+	modeKindNewGame modeKind = iota
+	modeKindContinue
+	modeKindCustom
+	modeKindSettings
+)
+
 type mode struct {
-	text      string
-	fieldSize int
+	text       string
+	kind       modeKind
+	difficulty *core.Difficulty
+	// seed is the seed to generate a field with. A random seed is chosen when loading starts unless fixedSeed is true.
+	seed      uint64
+	fixedSeed bool
 	x         int
 	y         int
 }
@@ -33,22 +53,44 @@ func (m *mode) size() (int, int) {
 	return font.ArcadeFont.TextWidth(m.text), font.ArcadeFont.TextHeight(m.text)
 }
 
+type loadingResult struct {
+	gameScene *gameScene
+	err       error
+}
+
 type titleScene struct {
 	game         *Game
-	gameScene    *gameScene
 	modes        []*mode
 	selectedMode *mode
-	loadingCh    chan error
+	message      string
+
+	// loadingCh is not nil while a game scene is being loaded.
+	loadingCh     chan loadingResult
+	cancelLoading context.CancelFunc
+	progressCh    chan core.GeneratorProgress
+	progress      core.GeneratorProgress
+
+	// loaded is the loaded game scene shown with its rating for a while before starting.
+	loaded       *gameScene
+	loadedFrames int
 }
 
 func newTitleScene(game *Game) *titleScene {
-	y := screenHeight - 32 - 64
-	modes := []*mode{
-		{"EASY", 2, 0, y},
-		{"NORMAL", 4, 0, y + 16},
-		{"HARD", 6, 0, y + 32},
-		{"EXTREME", 8, 0, y + 48},
+	y := screenHeight - 32 - 80
+	var modes []*mode
+	if hasSaveFile() {
+		modes = append(modes, &mode{text: "CONTINUE", kind: modeKindContinue, y: y - 24})
 	}
+	for i, d := range core.Difficulties {
+		modes = append(modes, &mode{
+			text:       d.Name,
+			kind:       modeKindNewGame,
+			difficulty: d,
+			y:          y + 16*i,
+		})
+	}
+	modes = append(modes, &mode{text: "CUSTOM", kind: modeKindCustom, y: y + 16*len(core.Difficulties)})
+	modes = append(modes, &mode{text: "SETTINGS", kind: modeKindSettings, y: y + 16*(len(core.Difficulties)+1) + 8})
 	maxWidth := 0
 	for _, m := range modes {
 		w, _ := m.size()
@@ -66,44 +108,131 @@ func newTitleScene(game *Game) *titleScene {
 }
 
 func (t *titleScene) Update() error {
-	if t.loadingCh == nil {
-		t.selectedMode = nil
-		x, y := ebiten.CursorPosition()
-		for _, m := range t.modes {
-			w, h := m.size()
-			if m.x <= x && x < m.x+w && m.y <= y && y < m.y+h {
-				t.selectedMode = m
-				break
-			}
+	if t.loaded != nil {
+		t.loadedFrames--
+		if t.loadedFrames <= 0 || t.game.input.IsTriggered() {
+			t.loaded.startTime = time.Now()
+			t.game.goTo(t.loaded)
+		}
+		return nil
+	}
+	if t.loadingCh != nil {
+		return t.updateLoading()
+	}
+	t.selectedMode = nil
+	x, y := ebiten.CursorPosition()
+	for _, m := range t.modes {
+		w, h := m.size()
+		if m.x <= x && x < m.x+w && m.y <= y && y < m.y+h {
+			t.selectedMode = m
+			break
 		}
 	}
-	if t.game.input.IsTriggered() && t.selectedMode != nil && t.loadingCh == nil {
-		t.loadingCh = make(chan error)
-		m := t.selectedMode
-		go func() {
-			defer close(t.loadingCh)
-			s, err := newGameScene(m.fieldSize, m.fieldSize, m.fieldSize, m.fieldSize, t.game)
-			if err != nil {
-				t.loadingCh <- err
-				return
-			}
-			t.gameScene = s
-		}()
+	if !t.game.input.IsTriggered() || t.selectedMode == nil {
+		return nil
+	}
+	switch t.selectedMode.kind {
+	case modeKindCustom:
+		t.game.goTo(newCustomScene(t.game))
+		return nil
+	case modeKindSettings:
+		t.game.goTo(newSettingsScene(t.game))
+		return nil
+	}
+	t.startLoading(t.selectedMode)
+	return nil
+}
+
+func (t *titleScene) updateLoading() error {
+	if t.game.input.IsKeyTriggered(ebiten.KeyEscape) {
+		// The loading goroutine ends soon and its result is discarded.
+		t.stopLoading()
 		return nil
 	}
 	select {
-	case err := <-t.loadingCh:
-		if err != nil {
-			return err
+	case p := <-t.progressCh:
+		t.progress = p
+	default:
+	}
+	var r loadingResult
+	select {
+	case r = <-t.loadingCh:
+	default:
+		return nil
+	}
+	t.stopLoading()
+	if r.err == nil {
+		if t.selectedMode.kind == modeKindContinue {
+			t.game.goTo(r.gameScene)
+			return nil
+		}
+		t.loaded = r.gameScene
+		t.loadedFrames = ebiten.TPS()
+		return nil
+	}
+	switch {
+	case t.selectedMode.kind == modeKindContinue:
+		log.Printf("switches: failed to load the save data: %v", r.err)
+		t.message = saveErrorMessage(r.err)
+		for i, m := range t.modes {
+			if m.kind == modeKindContinue {
+				t.modes = append(t.modes[:i], t.modes[i+1:]...)
+				break
+			}
 		}
-		t.game.goTo(t.gameScene)
 	default:
+		// The game goes on with the message even for an unexpected error.
+		log.Printf("switches: failed to generate a field: %v", r.err)
+		t.message = "GENERATION FAILED"
 	}
 	return nil
 }
 
+// startLoading starts loading the game scene for the mode m in background.
+func (t *titleScene) startLoading(m *mode) {
+	t.selectedMode = m
+	ctx, cancel := context.WithCancel(context.Background())
+	// The channels are buffered so that the goroutine never blocks even after the loading is canceled.
+	ch := make(chan loadingResult, 1)
+	progressCh := make(chan core.GeneratorProgress, 1)
+	t.loadingCh = ch
+	t.cancelLoading = cancel
+	t.progressCh = progressCh
+	t.progress = core.GeneratorProgress{}
+	if !m.fixedSeed {
+		m.seed = rand.Uint64()
+	}
+	seed := m.seed
+	go func() {
+		if m.kind == modeKindContinue {
+			s, err := loadGameScene(t.game)
+			ch <- loadingResult{s, err}
+			return
+		}
+		f, err := m.difficulty.NewFieldContext(ctx, seed, progressCh)
+		if err != nil {
+			ch <- loadingResult{nil, err}
+			return
+		}
+		ch <- loadingResult{newGameScene(f, m.difficulty, t.game), nil}
+	}()
+}
+
+func (t *titleScene) stopLoading() {
+	t.cancelLoading()
+	t.loadingCh = nil
+	t.cancelLoading = nil
+	t.progressCh = nil
+}
+
 func (t *titleScene) Draw(screen *ebiten.Image) {
 	screen.Fill(backgroundColor)
+	if t.loaded != nil {
+		msg := fmt.Sprintf("RATING %s", ratingString(t.loaded.rating))
+		w := font.ArcadeFont.TextWidth(msg)
+		font.ArcadeFont.DrawText(screen, msg, (screenWidth-w*2)/2, (screenHeight-16)/2, 2, color.White)
+		return
+	}
 	if t.loadingCh == nil {
 		title := "SWITCHES"
 		w := font.ArcadeFont.TextWidth(title)
@@ -116,7 +245,22 @@ func (t *titleScene) Draw(screen *ebiten.Image) {
 			}
 			font.ArcadeFont.DrawText(screen, m.text, m.x, m.y, 1, clr)
 		}
+		if t.message != "" {
+			w := font.ArcadeFont.TextWidth(t.message)
+			font.ArcadeFont.DrawText(screen, t.message, (screenWidth-w)/2, 104, 1, color.White)
+		}
 		return
 	}
 	font.ArcadeFont.DrawText(screen, "NOW LOADING...", 8, 8, 1, color.White)
+	if t.selectedMode.kind == modeKindContinue || t.progress.Attempts == 0 {
+		return
+	}
+	// The bar shows how many rooms the current walk has visited.
+	x, y, w, h := float32(8), float32(24), float32(screenWidth-16), float32(8)
+	vector.StrokeRect(screen, x, y, w, h, 1, color.White, false)
+	if 0 < t.progress.MaxRooms {
+		vector.DrawFilledRect(screen, x, y, w*float32(t.progress.Rooms)/float32(t.progress.MaxRooms), h, color.White, false)
+	}
+	font.ArcadeFont.DrawText(screen, fmt.Sprintf("ATTEMPT %d", t.progress.Attempts), 8, 40, 1, color.White)
+	font.ArcadeFont.DrawText(screen, "ESC: CANCEL", 8, screenHeight-16, 1, color.White)
 }